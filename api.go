@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lair-framework/api-server/client"
+	"github.com/lair-framework/go-lair"
+)
+
+// connOptions controls how the lair API server is reached.
+type connOptions struct {
+	user         string
+	passwordFile string
+	insecureSSL  bool
+	proxy        string
+	caCert       string
+	clientCert   string
+	clientKey    string
+
+	// connectTimeout limits connecting to the server, including the TLS
+	// handshake, and timeout limits each request as a whole. Zero means no
+	// limit.
+	connectTimeout time.Duration
+	timeout        time.Duration
+
+	// retries is how many times a request that timed out or failed with a
+	// 502, 503, or 504 is retried.
+	retries int
+}
+
+// apiClient is a client for the lair API server. The api-server client
+// holds the server and credentials, while requests are sent with an
+// http.Client drone-nmap configures so that proxies can be used.
+type apiClient struct {
+	*client.C
+	http    *http.Client
+	retries int
+}
+
+const (
+	// retryBackoff is the wait before the first retry, doubled for each
+	// retry after it up to maxRetryBackoff.
+	retryBackoff    = time.Second
+	maxRetryBackoff = 30 * time.Second
+)
+
+// newHTTPClient returns the http.Client for requests to the lair API server.
+func newHTTPClient(co *connOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: co.insecureSSL}
+	if co.caCert != "" {
+		pool, err := readCACert(co.caCert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if co.clientCert != "" || co.clientKey != "" {
+		if co.clientCert == "" || co.clientKey == "" {
+			return nil, errors.New("Both -client-cert and -client-key are required for client certificate authentication")
+		}
+		cert, err := tls.LoadX509KeyPair(co.clientCert, co.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load client certificate. Error %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: co.connectTimeout}).DialContext,
+		TLSHandshakeTimeout: co.connectTimeout,
+		TLSClientConfig:     tlsConfig,
+	}
+	if co.proxy != "" {
+		u, err := url.Parse(co.proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy %q", co.proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("Invalid proxy %q, expected an http, https, or socks5 URL", co.proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: transport, Timeout: co.timeout}, nil
+}
+
+// readCACert returns the system certificate pool with the PEM encoded
+// certificates in path added to it.
+func readCACert(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read CA certificate %s. Error %s", path, err.Error())
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No PEM encoded certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// url returns the URL of path on the lair API server.
+func (c *apiClient) url(path string, query url.Values) string {
+	u := url.URL{Scheme: c.Scheme, Host: c.Host, Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// importProject sends project to the lair API server, as the api-server
+// client's ImportProject does.
+func (c *apiClient) importProject(dOpts *client.DOptions, project *lair.Project) (*http.Response, error) {
+	body, err := json.Marshal(project)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("force-ports", fmt.Sprint(dOpts.ForcePorts))
+	query.Set("limit-hosts", fmt.Sprint(dOpts.LimitHosts))
+	return c.do("PATCH", c.url("/api/projects/"+url.PathEscape(project.ID), query), body)
+}
+
+// get requests path from the lair API server and unmarshals the JSON
+// response into v.
+func (c *apiClient) get(path string, v interface{}) error {
+	u := c.url(path, nil)
+	debugf("Requesting %s", u)
+	res, err := c.do("GET", u, nil)
+	if err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Could not reach the API server. Error %s", err.Error()))
+	}
+	defer res.Body.Close()
+	debugf("API server returned %s", res.Status)
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Could not read response. Error %s", err.Error()))
+	}
+	if res.StatusCode != http.StatusOK {
+		return withExitCode(apiExitCode(res.StatusCode), fmt.Errorf("Request for %s failed, server returned %s", path, res.Status))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Could not unmarshal JSON. Error %s", err.Error()))
+	}
+	return nil
+}
+
+// do sends a request to the lair API server, retrying up to c.retries times
+// with exponential backoff and jitter when the request times out or the
+// server returns 502, 503, or 504.
+func (c *apiClient) do(method, u string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, u, reader)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.User, c.Password)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		res, err := c.http.Do(req)
+		reason := ""
+		switch {
+		case err != nil:
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+				return nil, err
+			}
+			reason = "request timed out"
+		case isRetryStatus(res.StatusCode):
+			reason = "server returned " + res.Status
+		default:
+			return res, nil
+		}
+		if attempt == c.retries {
+			if err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		wait := retryWait(attempt)
+		log.Printf("Warning: %s %s %s, retrying in %s (%d of %d)", method, req.URL.Path, reason, wait.Round(time.Millisecond), attempt+1, c.retries)
+		time.Sleep(wait)
+	}
+}
+
+// isRetryStatus reports whether status is a transient gateway error worth
+// retrying.
+func isRetryStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryWait returns how long to wait before retry attempt+1: the backoff for
+// the attempt with up to half of it replaced by random jitter.
+func retryWait(attempt int) time.Duration {
+	backoff := maxRetryBackoff
+	if attempt < 5 {
+		backoff = retryBackoff << uint(attempt)
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/lair-framework/api-server/client"
+)
+
+// Archive formats that may contain several scan files.
+const (
+	archiveZip = "zip"
+	archiveTar = "tar"
+)
+
+var zipMagic = []byte("PK\x03\x04")
+
+// archiveType inspects the beginning of the input and returns the type of
+// archive it contains, or an empty string if it is not an archive.
+func archiveType(br *bufio.Reader) string {
+	head, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return archiveZip
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar
+	}
+	return ""
+}
+
+// importArchive imports every scan file contained in a zip or tar archive.
+// Each entry is parsed and imported separately and the result for each one
+// is logged.
+func importArchive(c *apiClient, dOpts *client.DOptions, r io.Reader, kind, name, projectID string, opts *options) error {
+	total := 0
+	failed := 0
+	code := exitParse
+	err := eachArchiveEntry(r, kind, func(entry string, er io.Reader) error {
+		total++
+		label := name + ":" + entry
+		if err := importReader(c, dOpts, er, projectID, opts); err != nil {
+			log.Printf("Error: %s: %s", label, err.Error())
+			if failed == 0 {
+				code = exitCode(err)
+			}
+			failed++
+			return nil
+		}
+		log.Printf("Success: %s %s successfully", label, opts.imported())
+		return nil
+	})
+	if err != nil {
+		return withExitCode(exitParse, fmt.Errorf("Could not read archive. Error %s", err.Error()))
+	}
+	if total == 0 {
+		return withExitCode(exitParse, fmt.Errorf("Archive contains no scan files"))
+	}
+	if failed > 0 {
+		return withExitCode(code, fmt.Errorf("%d of %d archive entries failed to import", failed, total))
+	}
+	return nil
+}
+
+// eachArchiveEntry calls fn with the name and decompressed contents of every
+// regular file in the archive. Zip archives are read into memory since they
+// require random access.
+func eachArchiveEntry(r io.Reader, kind string, fn func(entry string, r io.Reader) error) error {
+	switch kind {
+	case archiveZip:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || skipArchiveEntry(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = eachDecompressed(f.Name, rc, fn)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	case archiveTar:
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg || skipArchiveEntry(hdr.Name) {
+				continue
+			}
+			if err := eachDecompressed(hdr.Name, tr, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eachDecompressed decompresses an archive entry before passing it to fn.
+func eachDecompressed(entry string, r io.Reader, fn func(entry string, r io.Reader) error) error {
+	dr, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	return fn(entry, dr)
+}
+
+// skipArchiveEntry reports whether an archive entry is metadata rather than
+// scan data, such as the resource forks macOS adds to zip files.
+func skipArchiveEntry(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/lair-framework/api-server/client"
+	"github.com/lair-framework/go-lair"
+)
+
+// splitProject splits project into projects of at most size hosts each, or
+// returns it whole when size is zero or it is small enough. The first batch
+// carries the issues, commands, notes, and other project data along with
+// its hosts, and the rest carry only hosts.
+func splitProject(project *lair.Project, size int) []*lair.Project {
+	if size <= 0 || len(project.Hosts) <= size {
+		return []*lair.Project{project}
+	}
+	var batches []*lair.Project
+	for start := 0; start < len(project.Hosts); start += size {
+		end := start + size
+		if end > len(project.Hosts) {
+			end = len(project.Hosts)
+		}
+		var batch lair.Project
+		if start == 0 {
+			batch = *project
+		} else {
+			batch = lair.Project{ID: project.ID, Tool: project.Tool}
+		}
+		batch.Hosts = project.Hosts[start:end]
+		batches = append(batches, &batch)
+	}
+	return batches
+}
+
+// sendBatches sends batches to the lair API server. The first batch is sent
+// on its own, and the rest are sent opts.concurrency at a time. A failed
+// batch is logged and the others are still sent.
+func sendBatches(c *apiClient, dOpts *client.DOptions, batches []*lair.Project, opts *options) error {
+	if len(batches) == 1 {
+		if err := sendProject(c, dOpts, batches[0]); err != nil {
+			return err
+		}
+		opts.summary.record(batches[0])
+		return nil
+	}
+
+	var (
+		mu       sync.Mutex
+		failed   int
+		firstErr error
+		imported int
+		total    int
+	)
+	for _, batch := range batches {
+		total += len(batch.Hosts)
+	}
+	send := func(i int) error {
+		log.Printf("Info: Importing batch %d of %d, %d hosts", i+1, len(batches), len(batches[i].Hosts))
+		if err := sendProject(c, dOpts, batches[i]); err != nil {
+			return err
+		}
+		mu.Lock()
+		imported += len(batches[i].Hosts)
+		opts.summary.record(batches[i])
+		mu.Unlock()
+		return nil
+	}
+	if err := send(0); err != nil {
+		return withExitCode(exitCode(err), fmt.Errorf("Batch 1 of %d failed, no hosts were imported. %s", len(batches), err.Error()))
+	}
+
+	workers := opts.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := send(i); err != nil {
+					log.Printf("Error: Batch %d of %d failed, %d hosts were not imported. %s", i+1, len(batches), len(batches[i].Hosts), err.Error())
+					mu.Lock()
+					failed++
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 1; i < len(batches); i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if failed > 0 {
+		return withExitCode(exitCode(firstErr), fmt.Errorf("%d of %d batches failed, %d of %d hosts were imported", failed, len(batches), imported, total))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+	utf16LELt  = []byte{'<', 0x00}
+	utf16BELt  = []byte{0x00, '<'}
+)
+
+// transcode returns a reader of r converted to UTF-8 without a byte order
+// mark. UTF-16 input is recognized by its byte order mark or, for XML, by
+// the encoding of the leading "<". Other input is returned unchanged.
+func transcode(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		br.Discard(len(utf8BOM))
+	case bytes.HasPrefix(head, utf16LEBOM):
+		br.Discard(len(utf16LEBOM))
+		return &utf16Reader{r: br, order: littleEndian}
+	case bytes.HasPrefix(head, utf16BEBOM):
+		br.Discard(len(utf16BEBOM))
+		return &utf16Reader{r: br, order: bigEndian}
+	case bytes.HasPrefix(head, utf16LELt):
+		return &utf16Reader{r: br, order: littleEndian}
+	case bytes.HasPrefix(head, utf16BELt):
+		return &utf16Reader{r: br, order: bigEndian}
+	}
+	return br
+}
+
+const (
+	littleEndian = iota
+	bigEndian
+)
+
+// utf16Reader converts a stream of UTF-16 code units to UTF-8.
+type utf16Reader struct {
+	r     *bufio.Reader
+	order int
+	buf   []byte
+	err   error
+
+	// unread is a code unit read past the end of an unpaired surrogate, to
+	// be returned by the next readUnit.
+	unread    uint16
+	hasUnread bool
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.buf) < len(p) && u.err == nil {
+		r, err := u.readRune()
+		if err != nil {
+			u.err = err
+			break
+		}
+		var enc [utf8.UTFMax]byte
+		n := utf8.EncodeRune(enc[:], r)
+		u.buf = append(u.buf, enc[:n]...)
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	if n == 0 && u.err != nil {
+		return 0, u.err
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) readRune() (rune, error) {
+	c, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(c)) {
+		return rune(c), nil
+	}
+	if c >= 0xdc00 {
+		// A low surrogate without a high surrogate before it.
+		return utf8.RuneError, nil
+	}
+	c2, err := u.readUnit()
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	r := utf16.DecodeRune(rune(c), rune(c2))
+	if r == utf8.RuneError {
+		// A high surrogate without a low surrogate after it. The unit
+		// after it is kept for the next rune.
+		u.unread, u.hasUnread = c2, true
+	}
+	return r, nil
+}
+
+func (u *utf16Reader) readUnit() (uint16, error) {
+	if u.hasUnread {
+		u.hasUnread = false
+		return u.unread, nil
+	}
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if u.order == littleEndian {
+		return uint16(b[0]) | uint16(b[1])<<8, nil
+	}
+	return uint16(b[1]) | uint16(b[0])<<8, nil
+}
+
+// charsetReader is used by the XML decoder for documents that declare an
+// encoding other than UTF-8. UTF-16 input has already been converted by
+// transcode, so only single byte encodings need converting here.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8", "utf-16", "utf-16le", "utf-16be", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+		return &singleByteReader{r: bufio.NewReader(input)}, nil
+	case "windows-1252", "cp1252":
+		return &singleByteReader{r: bufio.NewReader(input), table: &cp1252}, nil
+	}
+	return nil, fmt.Errorf("Unsupported XML encoding %s", label)
+}
+
+// singleByteReader converts ISO-8859-1 input, or Windows-1252 input when a
+// table for the 0x80-0x9f range is set, to UTF-8.
+type singleByteReader struct {
+	r     *bufio.Reader
+	table *[32]rune
+	buf   []byte
+}
+
+func (s *singleByteReader) Read(p []byte) (int, error) {
+	for len(s.buf) < len(p) {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			if len(s.buf) == 0 {
+				return 0, err
+			}
+			break
+		}
+		r := rune(c)
+		if s.table != nil && c >= 0x80 && c < 0xa0 {
+			r = s.table[c-0x80]
+		}
+		var enc [utf8.UTFMax]byte
+		n := utf8.EncodeRune(enc[:], r)
+		s.buf = append(s.buf, enc[:n]...)
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// cp1252 maps the bytes 0x80-0x9f of Windows-1252 to unicode.
+var cp1252 = [32]rune{
+	0x20ac, 0xfffd, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0xfffd, 0x017d, 0xfffd,
+	0xfffd, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0xfffd, 0x017e, 0x0178,
+}
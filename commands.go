@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/lair-framework/go-lair"
+)
+
+// Commands given as the first argument after any options. Without one,
+// import is assumed.
+const (
+	commandImport   = "import"
+	commandScan     = "scan"
+	commandValidate = "validate"
+	commandConvert  = "convert"
+	commandDiff     = "diff"
+	commandProjects = "projects"
+)
+
+var commands = []string{commandImport, commandScan, commandValidate, commandConvert, commandDiff, commandProjects}
+
+// parseCommand parses the options in args with fs and returns the command
+// that follows them with its positional arguments, or import and all of the
+// positional arguments when there is no command, as in the flat invocation
+// of earlier versions. Options may also follow the command, except for scan,
+// whose arguments are left for parseScanArgs.
+func parseCommand(fs *flag.FlagSet, args []string) (string, []string) {
+	fs.Parse(args)
+	for _, command := range commands {
+		if fs.Arg(0) != command {
+			continue
+		}
+		if command == commandScan {
+			return command, fs.Args()[1:]
+		}
+		fs.Parse(fs.Args()[1:])
+		return command, fs.Args()
+	}
+	return commandImport, fs.Args()
+}
+
+// listProjects writes the id and name of every project on the lair API
+// server to standard output.
+func listProjects(c *apiClient) error {
+	var projects []lair.Project
+	if err := c.get("/api/projects", &projects); err != nil {
+		return err
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+	for _, p := range projects {
+		fmt.Fprintf(os.Stdout, "%s\t%s\n", p.ID, p.Name)
+	}
+	return nil
+}
+
+// logDiff logs the hosts and services of project that are not already in
+// the lair project, and counts the existing hosts the inputs did not find.
+func logDiff(project *lair.Project, existing *existingHosts) {
+	newHosts, newServices := 0, 0
+	seen := map[string]bool{}
+	for _, h := range project.Hosts {
+		seen[h.IPv4] = true
+		known, ok := existing.services[h.IPv4]
+		if !ok {
+			newHosts++
+			newServices += len(h.Services)
+			log.Printf("Info: New host %s with %d services", h.IPv4, len(h.Services))
+			continue
+		}
+		for _, s := range h.Services {
+			if !known[serviceKey(&s)] {
+				newServices++
+				log.Printf("Info: New service %s %s %s", h.IPv4, serviceKey(&s), s.Service)
+			}
+		}
+	}
+	missing := 0
+	for ip := range existing.services {
+		if !seen[ip] {
+			missing++
+			verbosef("Host %s is in the project but not in the inputs", ip)
+		}
+	}
+	log.Printf("Summary: %d new hosts, %d new services, %d hosts in the project not in the inputs", newHosts, newServices, missing)
+}
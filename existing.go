@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/lair-framework/go-lair"
+)
+
+// existingHosts records the hosts and services already in a lair project,
+// so -only-new can skip them.
+type existingHosts struct {
+	services map[string]map[string]bool
+}
+
+// fetchExisting exports projectID from the lair API server and records its
+// hosts and services.
+func fetchExisting(c *apiClient, projectID string) (*existingHosts, error) {
+	project := lair.Project{}
+	if err := c.get("/api/projects/"+url.PathEscape(projectID), &project); err != nil {
+		return nil, err
+	}
+	existing := &existingHosts{services: map[string]map[string]bool{}}
+	for _, h := range project.Hosts {
+		services := map[string]bool{}
+		for _, s := range h.Services {
+			services[serviceKey(&s)] = true
+		}
+		existing.services[h.IPv4] = services
+	}
+	return existing, nil
+}
+
+// serviceKey identifies a service on a host, such as 80/tcp.
+func serviceKey(s *lair.Service) string {
+	return fmt.Sprintf("%d/%s", s.Port, s.Protocol)
+}
+
+// onlyNew reduces a host that is already in the project to its address and
+// the services the project does not have yet, so the existing host record is
+// left untouched. It returns false when the host is already in the project
+// and has no new services.
+func (e *existingHosts) onlyNew(host *lair.Host) bool {
+	known, ok := e.services[host.IPv4]
+	if !ok {
+		return true
+	}
+	var services []lair.Service
+	for _, s := range host.Services {
+		if !known[serviceKey(&s)] {
+			services = append(services, s)
+		}
+	}
+	*host = lair.Host{IPv4: host.IPv4, Services: services}
+	return len(services) > 0
+}
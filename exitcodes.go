@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+)
+
+// Exit codes, so scripts can tell why an import failed.
+const (
+	exitFailure = 1 // usage and other errors
+	exitParse   = 2 // an input could not be read or parsed
+	exitEmpty   = 3 // no hosts were left to import after filtering
+	exitAuth    = 4 // the API server rejected the credentials
+	exitAPI     = 5 // the API server could not be reached or failed
+)
+
+// exitError is an error with the exit code drone-nmap should return for it.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// withExitCode returns err with an exit code. A nil err returns nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code for err, exitFailure unless it has one.
+func exitCode(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitFailure
+}
+
+// apiExitCode returns the exit code for a failed API request with the given
+// HTTP status code.
+func apiExitCode(status int) int {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return exitAuth
+	}
+	return exitAPI
+}
+
+// fatal logs err and exits with its exit code.
+func fatal(err error) {
+	log.Printf("Fatal: %s", err.Error())
+	os.Exit(exitCode(err))
+}
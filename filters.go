@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+// parseCIDRs converts a list of comma separated addresses and CIDR ranges
+// into networks. A bare address is treated as a network of one host.
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range list {
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			n, err := parseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+	}
+	return nets, nil
+}
+
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid address or CIDR %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid address or CIDR %q", s)
+	}
+	return n, nil
+}
+
+// containsIP reports whether any of nets contains ip.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAddress returns the IPv4 address of h, or its IPv6 address if it has
+// none.
+func hostAddress(h *nmap.Host) string {
+	address := ""
+	for _, a := range h.Addresses {
+		switch a.AddrType {
+		case "ipv4":
+			return a.Addr
+		case "ipv6":
+			address = a.Addr
+		}
+	}
+	return address
+}
+
+// includesAddress reports whether a host with address is imported. When
+// include ranges are given the address must be in one of them, and it must
+// not be in any exclude range.
+func (o *options) includesAddress(address string) bool {
+	if len(o.includeNets) == 0 && len(o.excludeNets) == 0 {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return len(o.includeNets) == 0
+	}
+	if len(o.includeNets) > 0 && !containsIP(o.includeNets, ip) {
+		return false
+	}
+	return !containsIP(o.excludeNets, ip)
+}
+
+// portRange is an inclusive range of port numbers.
+type portRange struct {
+	low, high int
+}
+
+// parsePorts converts a port list such as "80,443,8000-9000" into ranges.
+func parsePorts(list string) ([]portRange, error) {
+	var ranges []portRange
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		bounds := strings.SplitN(s, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		high := low
+		if err == nil && len(bounds) == 2 {
+			high, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || low < 0 || high > 65535 || low > high {
+			return nil, fmt.Errorf("Invalid port or port range %q", s)
+		}
+		ranges = append(ranges, portRange{low, high})
+	}
+	return ranges, nil
+}
+
+// containsPort reports whether any of ranges contains port.
+func containsPort(ranges []portRange, port int) bool {
+	for _, r := range ranges {
+		if port >= r.low && port <= r.high {
+			return true
+		}
+	}
+	return false
+}
+
+// parseServices converts a comma separated list of service names into a
+// regular expression matching any of them. Each name may itself be a
+// regular expression, such as http.*, and must match the whole name.
+func parseServices(list string) (*regexp.Regexp, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, "(?:"+name+")")
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + strings.Join(names, "|") + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid service list %q. Error %s", list, err.Error())
+	}
+	return re, nil
+}
+
+// includesService reports whether a service named name is imported based on
+// the -services and -exclude-services filters.
+func (o *options) includesService(name string) bool {
+	if o.services != nil && !o.services.MatchString(name) {
+		return false
+	}
+	return o.excludeServices == nil || !o.excludeServices.MatchString(name)
+}
+
+// excludesHostnames reports whether a host with hostnames is skipped because
+// one of them matches -exclude-hostnames.
+func (o *options) excludesHostnames(hostnames []string) bool {
+	if o.excludeHostnames == nil {
+		return false
+	}
+	for _, name := range hostnames {
+		if o.excludeHostnames.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScriptPatterns converts a comma separated list of script ids and
+// patterns such as smb-vuln-* into a list.
+func parseScriptPatterns(list string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid script pattern %q", p)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// hasRequiredScript reports whether h has output from a script matching
+// -require-script, on the host or any of its ports.
+func (o *options) hasRequiredScript(h *nmap.Host) bool {
+	if len(o.requireScripts) == 0 {
+		return true
+	}
+	matches := func(scripts []nmap.Script) bool {
+		for _, script := range scripts {
+			for _, p := range o.requireScripts {
+				if ok, _ := path.Match(p, script.Id); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if matches(h.HostScripts) {
+		return true
+	}
+	for i := range h.Ports {
+		if matches(h.Ports[i].Scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGhost reports whether h was only marked up because host discovery was
+// skipped with -Pn, with no port answering and no script output. Such hosts
+// are skipped by -skip-ghosts.
+func (o *options) isGhost(h *nmap.Host) bool {
+	if !o.skipGhosts || h.Status.Reason != "user-set" || len(h.HostScripts) > 0 {
+		return false
+	}
+	for _, p := range h.Ports {
+		switch p.State.State {
+		case "open", "closed", "unfiltered":
+			return false
+		}
+		if len(p.Scripts) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// Input formats accepted by the -format flag.
+const (
+	formatAuto        = "auto"
+	formatXML         = "xml"
+	formatMasscanJSON = "masscan-json"
+	formatMasscanList = "masscan-list"
+	formatGnmap       = "gnmap"
+	formatNormal      = "normal"
+	formatRustscan    = "rustscan"
+	formatNaabu       = "naabu"
+	formatZmap        = "zmap"
+)
+
+// parseInput reads scan data in the format given by opts from r and builds a
+// lair project from it. When the format is "auto" it is detected from the
+// content. Input with out of scope hosts fails when -scope-action is abort.
+func parseInput(r io.Reader, projectID string, opts *options) (*lair.Project, error) {
+	outside := opts.scope.count()
+	project, err := parseProject(r, projectID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.scope.check(outside); err != nil {
+		return nil, err
+	}
+	debugf("Built %d hosts with %d issues", len(project.Hosts), len(project.Issues))
+	checkSharedMACs(project, opts)
+	return project, nil
+}
+
+// parseProject parses r into a lair project without any checks across hosts.
+func parseProject(r io.Reader, projectID string, opts *options) (*lair.Project, error) {
+	br := bufio.NewReader(transcode(limitInput(r, opts.maxInputSize)))
+	format := opts.format
+	if format == formatAuto {
+		format = detectFormat(br)
+		debugf("Detected %s input", format)
+	}
+	var run *nmap.NmapRun
+	var err error
+	switch format {
+	case formatXML:
+		project := newProject(projectID)
+		parsed := 0
+		runs, err := parseStream(br, opts, func(run *nmap.NmapRun, h *nmap.Host) error {
+			parsed++
+			opts.progress.addParsed(1)
+			addHost(project, run, h, opts)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		debugf("Parsed %d hosts from %d nmap runs", parsed, len(runs))
+		for _, run := range runs {
+			addCommand(project, run, opts)
+		}
+		if len(runs) > 1 {
+			project.Hosts = mergeHosts(project.Hosts)
+		}
+		return project, nil
+	case formatMasscanJSON:
+		run, err = parseMasscanJSON(br)
+	case formatMasscanList:
+		run, err = parseMasscanList(br)
+	case formatGnmap:
+		run, err = parseGnmap(br)
+	case formatNormal:
+		run, err = parseNormal(br)
+	case formatRustscan:
+		run, err = parseRustscan(br)
+	case formatNaabu:
+		run, err = parseNaabu(br)
+	case formatZmap:
+		run, err = parseZmap(br)
+	default:
+		return nil, fmt.Errorf("Unknown input format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts.progress.addParsed(len(run.Hosts))
+	debugf("Parsed %d hosts from %s input", len(run.Hosts), format)
+	return buildProject(run, projectID, opts)
+}
+
+// detectFormat inspects the beginning of the input to determine its format.
+// Anything unrecognized is assumed to be nmap XML.
+func detectFormat(br *bufio.Reader) string {
+	head, _ := br.Peek(4096)
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(head, []byte("#masscan")):
+		return formatMasscanList
+	case bytes.HasPrefix(head, []byte("saddr,")):
+		return formatZmap
+	case bytes.HasPrefix(head, []byte("{")) && !bytes.Contains(head, []byte(`"ports"`)):
+		return formatNaabu
+	case bytes.HasPrefix(head, []byte("[")), bytes.HasPrefix(head, []byte("{")):
+		return formatMasscanJSON
+	case bytes.HasPrefix(head, []byte("Host: ")), bytes.Contains(head, []byte("\nHost: ")):
+		return formatGnmap
+	case bytes.Contains(head, []byte("Nmap scan report for ")):
+		return formatNormal
+	case isRustscan(head):
+		return formatRustscan
+	}
+	return formatXML
+}
+
+// hostSet accumulates ports into hosts for formats that report a single
+// port per record.
+type hostSet struct {
+	run   *nmap.NmapRun
+	index map[string]int
+}
+
+func newHostSet(scanner string) *hostSet {
+	return &hostSet{
+		run:   &nmap.NmapRun{Scanner: scanner},
+		index: make(map[string]int),
+	}
+}
+
+// host returns the host for ip, adding it to the run if it has not been
+// seen before. The returned pointer is only valid until the next call.
+func (s *hostSet) host(ip string) *nmap.Host {
+	if i, ok := s.index[ip]; ok {
+		return &s.run.Hosts[i]
+	}
+	addrType := "ipv4"
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		addrType = "ipv6"
+	}
+	s.run.Hosts = append(s.run.Hosts, nmap.Host{
+		Status:    nmap.Status{State: "up"},
+		Addresses: []nmap.Address{{Addr: ip, AddrType: addrType}},
+	})
+	s.index[ip] = len(s.run.Hosts) - 1
+	return &s.run.Hosts[len(s.run.Hosts)-1]
+}
+
+// port returns the port of host ip with the given protocol and number,
+// adding it if it has not been seen before. The returned pointer is only
+// valid until the next call.
+func (s *hostSet) port(ip, protocol string, id int) *nmap.Port {
+	h := s.host(ip)
+	for i := range h.Ports {
+		if h.Ports[i].Protocol == protocol && h.Ports[i].PortId == id {
+			return &h.Ports[i]
+		}
+	}
+	h.Ports = append(h.Ports, nmap.Port{Protocol: protocol, PortId: id})
+	return &h.Ports[len(h.Ports)-1]
+}
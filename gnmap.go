@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+var (
+	gnmapHostRe    = regexp.MustCompile(`^Host: (\S+) \(([^)]*)\)`)
+	gnmapArgsRe    = regexp.MustCompile(`^# Nmap (\S+) scan initiated .* as: (.*)$`)
+	gnmapPortRe    = regexp.MustCompile(`^\d+/`)
+	gnmapIgnoredRe = regexp.MustCompile(`^(\S+) \((\d+)\)$`)
+)
+
+// parseGnmap parses nmap grepable (-oG) output. Each host line contains tab
+// separated fields such as "Status: Up" or "Ports: 22/open/tcp//ssh//OpenSSH 7.4/".
+func parseGnmap(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(tool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			if m := gnmapArgsRe.FindStringSubmatch(line); m != nil {
+				set.run.Version = m[1]
+				set.run.Args = m[2]
+			}
+			continue
+		}
+		m := gnmapHostRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		h := set.host(m[1])
+		if m[2] != "" && len(h.Hostnames) == 0 {
+			h.Hostnames = append(h.Hostnames, nmap.Hostname{Name: m[2]})
+		}
+		for _, field := range strings.Split(line, "\t")[1:] {
+			parts := strings.SplitN(field, ": ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "Status":
+				h.Status.State = strings.ToLower(parts[1])
+			case "Ports":
+				ports, err := parseGnmapPorts(parts[1])
+				if err != nil {
+					return nil, err
+				}
+				h.Ports = append(h.Ports, ports...)
+			case "Ignored State":
+				if im := gnmapIgnoredRe.FindStringSubmatch(parts[1]); im != nil {
+					count, _ := strconv.Atoi(im[2])
+					h.ExtraPorts = append(h.ExtraPorts, nmap.ExtraPorts{State: im[1], Count: count})
+				}
+			case "OS":
+				h.Os.OsMatches = append(h.Os.OsMatches, nmap.OsMatch{Name: parts[1]})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
+
+// parseGnmapPorts parses the value of a grepable Ports field. Entries are
+// separated by ", " and have the form
+// port/state/protocol/owner/service/rpcinfo/version/.
+func parseGnmapPorts(value string) ([]nmap.Port, error) {
+	var entries []string
+	for _, part := range strings.Split(value, ", ") {
+		// Version strings may themselves contain ", ".
+		if len(entries) > 0 && !gnmapPortRe.MatchString(part) {
+			entries[len(entries)-1] += ", " + part
+			continue
+		}
+		entries = append(entries, part)
+	}
+	var ports []nmap.Port
+	for _, entry := range entries {
+		fields := strings.Split(entry, "/")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("Invalid grepable port entry %q", entry)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid grepable port entry %q", entry)
+		}
+		p := nmap.Port{
+			PortId:   id,
+			Protocol: fields[2],
+			State:    nmap.State{State: fields[1]},
+			Owner:    nmap.Owner{Name: fields[3]},
+		}
+		name := fields[4]
+		if strings.HasPrefix(name, "ssl|") {
+			p.Service.Tunnel = "ssl"
+			name = strings.TrimPrefix(name, "ssl|")
+		}
+		p.Service.Name = strings.TrimSuffix(name, "?")
+		// Nmap replaces "/" with "|" in grepable version strings.
+		p.Service.Product = strings.Replace(fields[6], "|", "/", -1)
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// dnsBruteRe matches a "name - address" line in the output of dns-brute.
+var dnsBruteRe = regexp.MustCompile(`^(\S+) - (\S+)$`)
+
+// mapDNSBrute adds the names dns-brute resolved to the address of the host
+// as hostnames. The output is still imported as a note.
+func mapDNSBrute(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	found := false
+	for i := range script.Tables {
+		for j := range script.Tables[i].Table {
+			t := &script.Tables[i].Table[j]
+			b.addScriptHostname(tableElem(t, "hostname"), tableElem(t, "address"))
+			found = true
+		}
+	}
+	if !found {
+		for _, line := range strings.Split(script.Output, "\n") {
+			if m := dnsBruteRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				b.addScriptHostname(m[1], m[2])
+			}
+		}
+	}
+	return false
+}
+
+// mapHostmap adds the names the hostmap-* scripts found for the address of
+// the host as hostnames. The output is still imported as a note.
+func mapHostmap(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	for i := range script.Tables {
+		if script.Tables[i].Key != "hosts" {
+			continue
+		}
+		for _, e := range script.Tables[i].Elements {
+			b.addScriptHostname(e.Value, "")
+		}
+		return false
+	}
+	inHosts := false
+	for _, line := range strings.Split(script.Output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "hosts:":
+			inHosts = true
+		case inHosts && line != "" && !strings.Contains(line, ":"):
+			b.addScriptHostname(line, "")
+		default:
+			inHosts = false
+		}
+	}
+	return false
+}
+
+// addScriptHostname adds a name a script found to the hostnames of the host.
+// When address is given the name is only added if it resolved to the host.
+func (b *hostBuilder) addScriptHostname(name, address string) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if address != "" && address != b.host.IPv4 {
+		return
+	}
+	if name == "" || net.ParseIP(name) != nil || strings.ContainsAny(name, " */") || !strings.Contains(name, ".") {
+		return
+	}
+	b.host.Hostnames = appendUnique(b.host.Hostnames, name)
+}
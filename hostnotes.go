@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+// addHostnames adds each distinct hostname to the host. Where nmap reports
+// how a name was found, such as user for a name given on the command line or
+// PTR for reverse DNS, the sources of each name are listed in a host note.
+func (b *hostBuilder) addHostnames(hostnames []nmap.Hostname) {
+	var names []string
+	types := map[string][]string{}
+	for _, hostname := range hostnames {
+		name := strings.ToLower(strings.TrimSuffix(hostname.Name, "."))
+		if name == "" {
+			continue
+		}
+		names = appendUnique(names, name)
+		if hostname.Type != "" {
+			types[name] = appendUnique(types[name], hostname.Type)
+		}
+	}
+	b.host.Hostnames = appendUnique(b.host.Hostnames, names...)
+	if len(types) == 0 {
+		return
+	}
+	var lines []string
+	for _, name := range names {
+		if len(types[name]) > 0 {
+			lines = append(lines, name+": "+strings.Join(types[name], ", "))
+		}
+	}
+	b.addNote(nil, "Hostnames", strings.Join(lines, "\n"))
+}
+
+// addStatusReason records why nmap considered the host up as a host note. A
+// reason of user-set means the host was assumed up, for example with -Pn,
+// rather than responding to host discovery.
+func (b *hostBuilder) addStatusReason(status *nmap.Status) {
+	if status.Reason == "" {
+		return
+	}
+	b.addNote(nil, "Host status", fmt.Sprintf("State: %s\nReason: %s\nReason TTL: %d", status.State, status.Reason, int(status.ReasonTTL)))
+}
+
+// addExtraPorts summarizes the ports nmap scanned but did not list, such as
+// "997 closed (997 resets)", as a host note.
+func (b *hostBuilder) addExtraPorts(extraPorts []nmap.ExtraPorts) {
+	var lines []string
+	for _, e := range extraPorts {
+		line := fmt.Sprintf("%d %s", e.Count, e.State)
+		var reasons []string
+		for _, r := range e.Reasons {
+			reasons = append(reasons, fmt.Sprintf("%d %s", r.Count, r.Reason))
+		}
+		if len(reasons) > 0 {
+			line += " (" + strings.Join(reasons, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		b.addNote(nil, "Ports not shown", strings.Join(lines, "\n"))
+	}
+}
+
+// addTrace records the traceroute hops to the host as a host note.
+func (b *hostBuilder) addTrace(trace *nmap.Trace) {
+	if len(trace.Hops) == 0 {
+		return
+	}
+	lines := []string{"HOP  RTT       ADDRESS"}
+	if trace.Proto != "" {
+		lines[0] = fmt.Sprintf("Traceroute using port %d/%s\n", trace.Port, trace.Proto) + lines[0]
+	}
+	for _, hop := range trace.Hops {
+		rtt := "--"
+		if hop.RTT != "" {
+			rtt = hop.RTT + " ms"
+		}
+		address := hop.IPAddr
+		if address == "" {
+			address = "*"
+		}
+		if hop.Host != "" {
+			address = fmt.Sprintf("%s (%s)", hop.Host, address)
+		}
+		lines = append(lines, fmt.Sprintf("%-4d %-9s %s", int(hop.TTL), rtt, address))
+	}
+	b.addNote(nil, "Traceroute", strings.Join(lines, "\n"))
+}
+
+// addScanTimes records when nmap scanned the host as a host note, and when
+// its services were last seen as a note on each service, so the project
+// shows when the evidence was collected rather than when it was imported.
+// Hosts without their own times, such as those from ping scans, use the
+// start time of the scan. It must be called after the services are added.
+func (b *hostBuilder) addScanTimes(run *nmap.NmapRun, h *nmap.Host) {
+	start, end := time.Time(h.StartTime), time.Time(h.EndTime)
+	if start.Unix() <= 0 && run != nil {
+		start = time.Time(run.Start)
+	}
+	if start.Unix() <= 0 {
+		return
+	}
+	content := "Scan started: " + start.UTC().Format(time.RFC3339)
+	lastSeen := start
+	if end.Unix() > 0 {
+		content += "\nScan finished: " + end.UTC().Format(time.RFC3339)
+		lastSeen = end
+	}
+	seen := "Last seen: " + lastSeen.UTC().Format(time.RFC3339)
+	b.addNote(nil, "Last seen", seen+"\n"+content)
+	for i := range b.host.Services {
+		b.addNote(&b.host.Services[i], "Last seen", seen)
+	}
+}
+
+// addUptime records nmap's uptime guess and the time of the last boot as a
+// host note.
+func (b *hostBuilder) addUptime(uptime *nmap.Uptime) {
+	if uptime.Seconds == 0 && uptime.Lastboot == "" {
+		return
+	}
+	content := fmt.Sprintf("Uptime guess: %.3f days (%d seconds)", float64(uptime.Seconds)/86400, uptime.Seconds)
+	if uptime.Lastboot != "" {
+		content += "\nLast boot: " + uptime.Lastboot
+	}
+	b.addNote(nil, "Uptime", content)
+}
+
+// addSequences records how predictable the host's TCP sequence numbers, IP
+// IDs and TCP timestamps are as a host note, in the form of nmap's normal
+// output.
+func (b *hostBuilder) addSequences(h *nmap.Host) {
+	var lines []string
+	if h.TcpSequence.Difficulty != "" {
+		lines = append(lines, fmt.Sprintf("TCP Sequence Prediction: Difficulty=%d (%s)", h.TcpSequence.Index, h.TcpSequence.Difficulty))
+	}
+	if h.IpIdSequence.Class != "" {
+		lines = append(lines, "IP ID Sequence Generation: "+h.IpIdSequence.Class)
+	}
+	if h.TcpTsSequence.Class != "" {
+		lines = append(lines, "TCP Timestamp Sequence: "+h.TcpTsSequence.Class)
+	}
+	if len(lines) > 0 {
+		b.addNote(nil, "Sequence prediction", strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// mapHTTPEnum converts the paths found by http-enum into a note with one
+// path and its description per line.
+func mapHTTPEnum(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var lines []string
+	for _, line := range strings.Split(script.Output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) == 2 {
+			line = parts[0] + " - " + parts[1]
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	b.addScriptNote(service, script.Id, strings.Join(lines, "\n"))
+	return true
+}
+
+// mapHTTPMethods converts http-methods into a note listing the supported and
+// potentially risky methods. With -http-method-issues an issue is raised
+// when risky methods such as PUT, DELETE or TRACE are enabled.
+func mapHTTPMethods(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	supported := methodList(script, "Supported Methods")
+	risky := methodList(script, "Potentially risky methods")
+	if len(supported) == 0 && len(risky) == 0 {
+		return false
+	}
+
+	var lines []string
+	if len(supported) > 0 {
+		lines = append(lines, "Supported methods: "+strings.Join(supported, " "))
+	}
+	if len(risky) > 0 {
+		lines = append(lines, "Risky methods: "+strings.Join(risky, " "))
+	}
+	b.addScriptNote(service, script.Id, strings.Join(lines, "\n"))
+
+	if b.opts.httpMethodIssues && len(risky) > 0 {
+		issue := newIssue(script, "Potentially risky HTTP methods enabled")
+		issue.Description = "The web server allows HTTP methods that may let an attacker modify content or reflect requests: " + strings.Join(risky, ", ")
+		issue.Solution = "Disable HTTP methods that the application does not require."
+		issue.IsConfirmed = true
+		b.addIssue(service, issue)
+	}
+	return true
+}
+
+// methodList returns the HTTP methods http-methods listed under name, from
+// its structured output if present and otherwise from the text output.
+func methodList(script *nmap.Script, name string) []string {
+	for i := range script.Tables {
+		if script.Tables[i].Key != name {
+			continue
+		}
+		var methods []string
+		for _, e := range script.Tables[i].Elements {
+			methods = appendUnique(methods, strings.TrimSpace(e.Value))
+		}
+		return methods
+	}
+	if value := outputField(script.Output, name); value != "" {
+		return strings.Fields(value)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+var redirectRe = regexp.MustCompile(`(?i)redirect to (\S+)`)
+
+// mapHTTPTitle converts the http-title script into a note with the page
+// title and any redirect. With -redirect-hostnames the host of the redirect
+// is added to the hostnames of the host.
+func mapHTTPTitle(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	title := scriptElem(script, "title")
+	redirect := scriptElem(script, "redirect_url")
+	if redirect == "" {
+		if m := redirectRe.FindStringSubmatch(script.Output); m != nil {
+			redirect = strings.TrimRight(m[1], ".")
+		}
+	}
+	if title == "" && redirect == "" {
+		title = strings.TrimSpace(script.Output)
+	}
+
+	var lines []string
+	if title != "" {
+		lines = append(lines, "Title: "+title)
+	}
+	if redirect != "" {
+		lines = append(lines, "Redirect: "+redirect)
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	b.addScriptNote(service, script.Id, strings.Join(lines, "\n"))
+
+	if b.opts.redirectHostnames && redirect != "" {
+		if u, err := url.Parse(redirect); err == nil {
+			if name := strings.ToLower(u.Hostname()); name != "" && net.ParseIP(name) == nil {
+				b.host.Hostnames = appendUnique(b.host.Hostnames, name)
+			}
+		}
+	}
+	return true
+}
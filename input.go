@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stdinName is the input name used to read from standard input.
+const stdinName = "-"
+
+// expandInputs resolves the input arguments into a list of files to import.
+// Directories are searched recursively for nmap XML files and arguments
+// containing glob meta characters are expanded.
+func expandInputs(args []string) ([]string, error) {
+	var files []string
+	stdin := false
+	for _, arg := range args {
+		switch {
+		case arg == stdinName:
+			if stdin {
+				return nil, fmt.Errorf("Standard input may only be used once")
+			}
+			stdin = true
+			files = append(files, arg)
+		case isRemote(arg):
+			files = append(files, arg)
+		case isDir(arg):
+			found, err := findXMLFiles(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("No nmap XML files found in directory %s", arg)
+			}
+			files = append(files, found...)
+		case isGlob(arg):
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid glob pattern %s. Error %s", arg, err.Error())
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("No files matched pattern %s", arg)
+			}
+			for _, m := range matches {
+				if !isDir(m) {
+					files = append(files, m)
+				}
+			}
+		default:
+			files = append(files, arg)
+		}
+	}
+	return files, nil
+}
+
+// findXMLFiles walks dir and returns every regular file with an .xml
+// extension, including compressed .xml.gz and .xml.bz2 files. Named pipes
+// and devices are skipped since opening them may block indefinitely; they
+// can still be given explicitly as inputs.
+func findXMLFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && isXMLFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not read directory %s. Error %s", dir, err.Error())
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// inputExists reports whether arg refers to a file, directory, or glob
+// pattern with at least one match.
+func inputExists(arg string) bool {
+	if arg == stdinName || isRemote(arg) {
+		return true
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return true
+	}
+	if isGlob(arg) {
+		matches, _ := filepath.Glob(arg)
+		return len(matches) > 0
+	}
+	return false
+}
+
+// openInput opens the named input for reading, using standard input when
+// name is "-" and fetching name when it is an HTTP(S), S3, or SSH URL.
+// Compressed input is decompressed as it is read. The input is never read
+// into memory as a whole, so named pipes fed by another process work.
+func openInput(name string, opts *options) (io.ReadCloser, error) {
+	var f io.ReadCloser
+	switch {
+	case name == stdinName:
+		f = ioutil.NopCloser(os.Stdin)
+	case isURL(name):
+		body, err := fetchURL(name, opts.headers)
+		if err != nil {
+			return nil, err
+		}
+		f = body
+	case isS3(name):
+		body, err := fetchS3(name)
+		if err != nil {
+			return nil, err
+		}
+		f = body
+	case isSSH(name):
+		body, err := fetchSSH(name)
+		if err != nil {
+			return nil, err
+		}
+		f = body
+	default:
+		if info, err := os.Stat(name); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+			log.Printf("Info: Waiting for data on named pipe %s", name)
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		f = file
+	}
+	var total int64
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+		total = info.Size()
+	}
+	r, err := decompress(opts.progress.wrap(f, total))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &readCloser{Reader: limitInput(r, opts.maxInputSize), Closer: f}, nil
+}
+
+// readCloser combines a reader with the closer of the underlying input.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// decompress detects gzip or bzip2 compressed data by its magic bytes and
+// returns a reader of the decompressed contents. Uncompressed data is
+// returned as is.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(bzip2Magic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read gzip data. Error %s", err.Error())
+		}
+		return gz, nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
+
+func isXMLFile(path string) bool {
+	name := strings.ToLower(path)
+	for _, ext := range []string{".xml", ".xml.gz", ".xml.bz2"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchURL requests rawurl with the given headers and returns the response
+// body.
+func fetchURL(rawurl string, headers http.Header) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unexpected response from %s: %s", rawurl, res.Status)
+	}
+	return res.Body, nil
+}
+
+// parseHeaders converts a list of "Name: value" strings into headers.
+func parseHeaders(list []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, h := range list {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid header %q, expected 'Name: value'", h)
+		}
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return headers, nil
+}
+
+// isRemote reports whether name is a URL for any of the supported remote
+// input sources.
+func isRemote(name string) bool {
+	return isURL(name) || isS3(name) || isSSH(name)
+}
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+func isS3(name string) bool {
+	return strings.HasPrefix(name, "s3://")
+}
+
+func isSSH(name string) bool {
+	return strings.HasPrefix(name, "ssh://")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
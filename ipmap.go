@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+// ipMapping rewrites addresses in from to the same host in to.
+type ipMapping struct {
+	from *net.IPNet
+	to   *net.IPNet
+}
+
+// readIPMap reads an address translation file. Each line holds the address
+// or CIDR range that was scanned, an arrow, and the real address or range of
+// the same size, such as "127.0.0.2 -> 10.1.1.5" for a port forward or
+// "172.16.0.0/24 -> 10.2.0.0/24" for a NAT. Blank lines and lines starting
+// with # are ignored.
+func readIPMap(path string) ([]ipMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open IP map. Error %s", err.Error())
+	}
+	defer f.Close()
+	var mappings []ipMapping
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid IP mapping on line %d of %s, expected '<cidr> -> <cidr>'", line, path)
+		}
+		from, err := parseCIDR(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid IP mapping on line %d of %s. Error %s", line, path, err.Error())
+		}
+		to, err := parseCIDR(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid IP mapping on line %d of %s. Error %s", line, path, err.Error())
+		}
+		fromOnes, fromBits := from.Mask.Size()
+		toOnes, toBits := to.Mask.Size()
+		if fromOnes != toOnes || fromBits != toBits {
+			return nil, fmt.Errorf("Invalid IP mapping on line %d of %s, ranges must be the same size", line, path)
+		}
+		mappings = append(mappings, ipMapping{from: from, to: to})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read IP map. Error %s", err.Error())
+	}
+	return mappings, nil
+}
+
+// translateAddress returns the real address of address according to the
+// first mapping whose scanned range contains it, keeping the host part of
+// the address. Addresses that are not mapped are returned unchanged.
+func (o *options) translateAddress(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+	for _, m := range o.ipMap {
+		if !m.from.Contains(ip) {
+			continue
+		}
+		if v4 := ip.To4(); v4 != nil && len(m.from.IP) == net.IPv4len {
+			ip = v4
+		}
+		real := make(net.IP, len(m.to.IP))
+		for i := range real {
+			real[i] = m.to.IP[i] | ip[i]&^m.from.Mask[i]
+		}
+		return real.String()
+	}
+	return address
+}
+
+// translateHost returns h with its IP addresses rewritten by -ip-map, and the
+// address that was scanned if it changed. h itself is not modified.
+func (o *options) translateHost(h *nmap.Host) (*nmap.Host, string) {
+	if len(o.ipMap) == 0 {
+		return h, ""
+	}
+	scanned := hostAddress(h)
+	translated := *h
+	translated.Addresses = make([]nmap.Address, len(h.Addresses))
+	for i, address := range h.Addresses {
+		if address.AddrType == "ipv4" || address.AddrType == "ipv6" {
+			address.Addr = o.translateAddress(address.Addr)
+		}
+		translated.Addresses[i] = address
+	}
+	if hostAddress(&translated) == scanned {
+		return h, ""
+	}
+	return &translated, scanned
+}
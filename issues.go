@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// Default CVSS scores for findings that only report a risk factor.
+const (
+	cvssHigh   = 7.5
+	cvssMedium = 5.0
+	cvssLow    = 2.5
+)
+
+// newIssue returns an issue identified by script with the given title.
+func newIssue(script *nmap.Script, title string) lair.Issue {
+	return lair.Issue{
+		Title:          title,
+		CVSS:           cvssMedium,
+		Rating:         rating(cvssMedium),
+		PluginIDs:      []lair.PluginID{{Tool: tool, ID: script.Id}},
+		IdentifiedBy:   []lair.IdentifiedBy{{Tool: tool}},
+		Evidence:       script.Output,
+		LastModifiedBy: tool,
+	}
+}
+
+// addIssue records issue as affecting the host being built, and service
+// when it is not nil. The evidence is redacted with the -redact patterns.
+func (b *hostBuilder) addIssue(service *lair.Service, issue lair.Issue) {
+	issue.Evidence = b.opts.redact(issue.Evidence)
+	ih := lair.IssueHost{IPv4: b.host.IPv4}
+	if service != nil {
+		ih.Ports = append(ih.Ports, lair.IssuePort{Port: service.Port, Protocol: service.Protocol})
+	}
+	issue.Hosts = []lair.IssueHost{ih}
+	b.issues = append(b.issues, issue)
+}
+
+// mergeIssues adds each issue in add to issues. Issues with the same title
+// are combined into one issue listing every affected host and every script
+// that reported it.
+func mergeIssues(issues []lair.Issue, add ...lair.Issue) []lair.Issue {
+	for _, issue := range add {
+		found := false
+		for i := range issues {
+			if issues[i].Title != issue.Title {
+				continue
+			}
+			found = true
+			for _, h := range issue.Hosts {
+				issues[i].Hosts = mergeIssueHost(issues[i].Hosts, h)
+			}
+			issues[i].CVEs = appendUnique(issues[i].CVEs, issue.CVEs...)
+			for _, p := range issue.PluginIDs {
+				if !hasPluginID(issues[i].PluginIDs, p) {
+					issues[i].PluginIDs = append(issues[i].PluginIDs, p)
+				}
+			}
+			if issue.CVSS > issues[i].CVSS {
+				issues[i].CVSS = issue.CVSS
+				issues[i].Rating = issue.Rating
+			}
+			break
+		}
+		if !found {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// mergeIssueHost adds the ports of h to the matching host in hosts, or adds
+// h if the host is not yet listed.
+func mergeIssueHost(hosts []lair.IssueHost, h lair.IssueHost) []lair.IssueHost {
+	for i := range hosts {
+		if hosts[i].IPv4 != h.IPv4 {
+			continue
+		}
+		for _, p := range h.Ports {
+			found := false
+			for _, existing := range hosts[i].Ports {
+				if existing == p {
+					found = true
+					break
+				}
+			}
+			if !found {
+				hosts[i].Ports = append(hosts[i].Ports, p)
+			}
+		}
+		return hosts
+	}
+	return append(hosts, h)
+}
+
+func hasPluginID(ids []lair.PluginID, id lair.PluginID) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// rating converts a CVSS score into a lair issue rating.
+func rating(cvss float64) string {
+	switch {
+	case cvss >= 7.0:
+		return "high"
+	case cvss >= 4.0:
+		return "medium"
+	}
+	return "low"
+}
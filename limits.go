@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxXMLDepth is the default limit on XML element nesting. Nmap
+// output never nests more than a handful of elements except for script
+// tables, so this leaves plenty of room.
+const defaultMaxXMLDepth = 100
+
+// limitInput returns a reader that fails once more than max bytes have been
+// read from r. A max of zero or less disables the limit.
+func limitInput(r io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	return &sizeLimitReader{r: r, remaining: max, max: max}
+}
+
+// sizeLimitReader is an io.Reader that fails once a maximum size is
+// exceeded, rather than silently truncating like io.LimitReader.
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	max       int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("Input exceeds the maximum size of %d bytes", l.max)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fmt.Errorf("Input exceeds the maximum size of %d bytes", l.max)
+	}
+	return n, err
+}
+
+// limitTokenReader guards an XML decoder against hostile input by limiting
+// element nesting and rejecting entity declarations. The standard decoder
+// does not expand custom entities, but a document declaring them is not
+// nmap output and is refused outright.
+type limitTokenReader struct {
+	d        *xml.Decoder
+	depth    int
+	maxDepth int
+}
+
+func (l *limitTokenReader) Token() (xml.Token, error) {
+	tok, err := l.d.Token()
+	if err != nil {
+		return tok, err
+	}
+	switch t := tok.(type) {
+	case xml.StartElement:
+		l.depth++
+		if l.maxDepth > 0 && l.depth > l.maxDepth {
+			return nil, fmt.Errorf("XML nesting exceeds the maximum depth of %d", l.maxDepth)
+		}
+	case xml.EndElement:
+		l.depth--
+	case xml.Directive:
+		if bytes.Contains(t, []byte("ENTITY")) {
+			return nil, errors.New("XML entity declarations are not allowed")
+		}
+	}
+	return tok, nil
+}
+
+// parseSize parses a byte count with an optional K, M, or G suffix.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
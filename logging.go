@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"log"
+	"strings"
+)
+
+// Log levels set with -quiet, -verbose, and -debug.
+const (
+	levelQuiet = iota
+	levelNormal
+	levelVerbose
+	levelDebug
+)
+
+// logLevel is the current log level.
+var logLevel = levelNormal
+
+// setLogLevel sets the log level. When quiet only warnings, errors, and
+// fatal errors are logged.
+func setLogLevel(level int, w io.Writer) {
+	logLevel = level
+	if level == levelQuiet {
+		log.SetOutput(&quietWriter{w: w})
+	}
+}
+
+// verbosef logs an informational message when -verbose or -debug is set.
+func verbosef(format string, v ...interface{}) {
+	if logLevel >= levelVerbose {
+		log.Printf("Info: "+format, v...)
+	}
+}
+
+// debugf logs a debug message when -debug is set.
+func debugf(format string, v ...interface{}) {
+	if logLevel >= levelDebug {
+		log.Printf("Debug: "+format, v...)
+	}
+}
+
+// quietWriter drops Info, Success, and Summary log lines.
+type quietWriter struct {
+	w io.Writer
+}
+
+func (q *quietWriter) Write(p []byte) (int, error) {
+	// Lines start with the date and time, followed by the kind of message.
+	fields := strings.SplitN(string(p), " ", 4)
+	if len(fields) == 4 {
+		switch fields[2] {
+		case "Info:", "Success:", "Summary:":
+			return len(p), nil
+		}
+	}
+	return q.w.Write(p)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+)
+
+// sharedMACTag is added to hosts that share their MAC address with another
+// host when -shared-mac-tag is set.
+const sharedMACTag = "shared-mac"
+
+// checkSharedMACs warns about MAC addresses reported for more than one host.
+// This happens when hosts are behind NAT, a router answers with proxy ARP, or
+// the scan ran from a different segment, and means the MAC does not identify
+// the host.
+func checkSharedMACs(project *lair.Project, opts *options) {
+	counts := map[string]int{}
+	for _, h := range project.Hosts {
+		if h.MAC != "" {
+			counts[strings.ToUpper(h.MAC)]++
+		}
+	}
+	var shared []string
+	for mac, n := range counts {
+		if n > 1 {
+			shared = append(shared, mac)
+		}
+	}
+	if len(shared) == 0 {
+		return
+	}
+	sort.Strings(shared)
+	for _, mac := range shared {
+		log.Printf("Warning: %d hosts share MAC address %s, they may be behind NAT or proxy ARP", counts[mac], mac)
+	}
+	if !opts.sharedMACTag {
+		return
+	}
+	for i := range project.Hosts {
+		if counts[strings.ToUpper(project.Hosts[i].MAC)] > 1 {
+			project.Hosts[i].Tags = appendUnique(project.Hosts[i].Tags, sharedMACTag)
+		}
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/lair-framework/api-server/client"
 	"github.com/lair-framework/go-lair"
-	"github.com/lair-framework/go-nmap"
+	"golang.org/x/term"
 )
 
 const (
@@ -20,175 +25,873 @@ const (
 	tool     = "nmap"
 	osWeight = 50
 	usage    = `
-Parses an nmap XML file into a lair project.
+Parses an nmap XML file into a lair project. Nmap grepable (-oG) and normal
+(-oN) output, masscan JSON (-oJ) and list (-oL) output, rustscan output,
+naabu JSON output, and zmap CSV output are also supported.
 
 Usage:
-  drone-nmap [options] <id> <filename>
-  export LAIR_ID=<id>; drone-nmap [options] <filename>
+  drone-nmap [import] [options] <id> <input> [<input> ...]
+  export LAIR_ID=<id>; drone-nmap [import] [options] <input> [<input> ...]
+  drone-nmap [options] scan [<id>] -- <nmap arguments>
+  drone-nmap validate [options] <input> [<input> ...]
+  drone-nmap convert [options] <input> [<input> ...]
+  drone-nmap diff [options] <id> <input> [<input> ...]
+  drone-nmap projects [options]
+
+  import parses the inputs and imports them into the lair project, and is
+  assumed when no command is given. validate only parses the inputs and
+  reports any errors. convert writes the lair project JSON to standard output,
+  or the -o file, instead of importing it. diff lists the hosts and services
+  in the inputs that are not already in the lair project. projects lists the
+  id and name of every project on the lair API server. Options may be given
+  before or after the command, except for scan, where they come before it.
+
+  The lair API server is read from LAIR_API_SERVER, such as
+  https://lair.example.com. The username and password may be included in the
+  URL, but LAIR_USER and LAIR_PASSWORD or the -user and -password-file options
+  keep them out of the URL and process listings. When only the username is
+  given the password is prompted for on the terminal.
+
+  An input may be a file, a directory to search for .xml files, a glob
+  pattern such as 'scans/*.xml', an http:// or https:// URL, an
+  s3://bucket/key URL, an ssh://user@host/path URL, or '-' to read from
+  standard input. Named pipes created with mkfifo may be given as files. Zip
+  and tar archives are expanded and each file they contain is imported.
+
+  S3 credentials are read from the standard AWS environment variables and
+  shared files; set AWS_ENDPOINT_URL to use an S3 compatible server such as
+  MinIO. SSH inputs use the system ssh client and agent, and paths starting
+  with /~/ are relative to the remote home directory.
+
+  Vulnerabilities reported by NSE scripts such as vulners, smb-vuln-* and
+  http-vuln-* are imported as issues. Other script output is imported as
+  notes on the host or service.
+
+  MAC vendors missing from the scan are looked up in the IEEE registry built
+  into drone-nmap, or in nmap's nmap-mac-prefixes file when it is found in
+  NMAPDIR or the usual nmap install directories.
+
+  The scan command runs nmap with the given arguments and imports each host
+  as soon as nmap finishes scanning it.
 Options:
   -v              show version and exit
   -h              show usage and exit
   -k              allow insecure SSL connections
+  -proxy          the proxy to reach the lair API server through, such as
+                  http://127.0.0.1:8080 or socks5://127.0.0.1:9050. HTTP_PROXY,
+                  HTTPS_PROXY, and NO_PROXY are used when it is not given
+  -ca-cert        a PEM file of CA certificates to trust for the lair API server in
+                  addition to the system roots, instead of disabling verification
+                  with -k
+  -client-cert    a PEM client certificate for servers that require mutual TLS,
+                  used with -client-key
+  -client-key     the PEM private key for -client-cert
+  -connect-timeout
+                  how long to wait when connecting to the lair API server, such as
+                  10s (default 30s)
+  -timeout        how long to wait for each request to the lair API server,
+                  including sending large imports, such as 10m (default no limit)
+  -retries        retry requests to the lair API server that time out or fail with
+                  502, 503, or 504 this many times, waiting longer after each one
+  -batch-size     import at most this many hosts per request, sending batches one
+                  after another, for projects too large for a single request
+  -concurrency    with -batch-size, how many batches to send at once after the
+                  first, which carries the project's issues and commands (default 1)
+  -quiet          only log warnings and errors
+  -verbose        also log each host that is skipped and why
+  -debug          also log detected formats, parsed host counts, skipped services,
+                  and API requests and responses, without credentials
+  -user           the username for the lair API server, overriding LAIR_USER and
+                  the username in LAIR_API_SERVER
+  -password-file  a file whose first line is the password for the lair API server,
+                  overriding LAIR_PASSWORD and the password in LAIR_API_SERVER
+  -config         a YAML file of default options, read from ~/.drone-nmap.yaml when
+                  not given. Keys are option names without the dash, such as k, tags,
+                  or include-cidr, plus api-server and project-id for LAIR_API_SERVER
+                  and LAIR_ID. Options on the command line and environment variables
+                  take precedence
   -force-ports    disable data protection in the API server for excessive ports
-  -limit-hosts    only import hosts that have listening ports
-  -tags           a comma separated list of tags to add to every host that is imported
+  -dry-run        parse and filter the inputs and log the hosts, services, notes,
+                  issues, and tags that would be imported without importing them.
+                  LAIR_API_SERVER and LAIR_ID are not required unless -only-new is set
+  -confirm-over   ask for confirmation before importing more than this many hosts,
+                  failing when there is no terminal to ask on (default 0, never ask).
+                  Hosts are counted across every input, or the whole scan, so they
+                  are merged and imported together as with -merge
+  -yes            import without asking for confirmation under -confirm-over
+  -o              write the lair project to this JSON file, or standard output for -,
+                  instead of importing it. Every input is merged into one project and
+                  LAIR_API_SERVER and LAIR_ID are not required unless -only-new is set
+  -only-new       only import hosts and services that are not already in the lair
+                  project, leaving existing records untouched
+  -limit-hosts    only import hosts that have listening ports, host script output such
+                  as smb-os-discovery, or an OS match
+  -skip-ghosts    do not import hosts that were only marked up because nmap was run with
+                  -Pn, with a status reason of user-set and no open, closed, or
+                  unfiltered ports or script output
+  -min-ports      only import hosts with at least this many services after filtering
+                  (default 0)
+  -tags           a comma separated list of tags to add to every host that is imported,
+                  hosts are also tagged with their network distance such as hops:1
+                  and the scan that found them such as scan:syn or scan:top1000.
+                  Tags in the LAIR_TAGS environment variable are added as well
+  -command-note   a note on why the scan ran, such as 'quarterly external scan Q3',
+                  recorded with the nmap arguments in the project commands
+  -ip-map         a file of '<cidr> -> <cidr>' lines that rewrite scanned addresses to
+                  the real address of the host, such as '127.0.0.2 -> 10.1.1.5' for
+                  scans through a port forward or pivot. Filters and tag rules use
+                  the rewritten address
+  -tag-rules      a file of '<cidr> = <tags>' lines that tag the hosts in each range,
+                  such as '10.1.0.0/16 = dc,prod'
+  -service-map    a file of '<name> -> <name>' lines that rename services, such as
+                  'microsoft-ds -> smb'. Names are matched after SSL services are
+                  renamed, so 'ssl/http' is already 'https'
+  -service-tags   a file of '<services> = <tags>' lines that tag the services with
+                  matching names in a "Tags" service note, such as
+                  'https,ssl/.* = crypto'
+  -format         input format, one of auto, xml, gnmap, normal, masscan-json,
+                  masscan-list, rustscan, naabu, zmap (default auto)
+  -merge          parse every input before importing and merge hosts found in more than
+                  one, so each host is imported once. Services found in several inputs
+                  are taken from the most recent scan
+  -recover        import the complete hosts of a truncated nmap XML file
+  -input-header   a 'Name: value' header to send when fetching URL inputs, may be repeated
+  -input-list     a file listing inputs to import in order, one per line, each
+                  optionally followed by a comma separated list of tags
+  -max-input-size the maximum decompressed size of an input, such as 500M or 2G
+                  (default unlimited)
+  -max-xml-depth  the maximum XML element nesting depth (default 100)
+  -max-note-size  truncate script output notes larger than this size, such as 64K
+                  (default unlimited)
+  -skip-note-size drop script output notes larger than this size, such as 1M
+                  (default unlimited)
+  -redact         a file of regular expressions, one per line, matching sensitive data
+                  to replace with [REDACTED] in script output and issue evidence. When
+                  a pattern has groups, only the grouped text is replaced
+  -progress       periodically log hosts parsed and uploaded, percent complete, and ETA
+  -include-states a comma separated list of port states to import in addition to open,
+                  such as closed,filtered,open|filtered
+  -udp-open-filtered
+                  import UDP ports nmap reports as open|filtered, noting that they
+                  did not respond
+  -min-conf       the minimum nmap service detection confidence (0-10) for a service name
+                  and product to be imported, lower confidence guesses are kept as
+                  a note (default 0)
+  -redirect-hostnames
+                  add the hostnames of redirects reported by http-title to the host
+  -os-accuracy    the minimum accuracy percentage of an OS match to import (default 0)
+  -os-matches     the number of OS matches to list in a host note, the most accurate
+                  match is always used as the host OS (default 1, no note)
+  -os-tags        tag hosts with the OS family and device type nmap detected, such as
+                  os:windows or device:printer
+  -http-method-issues
+                  raise an issue when http-methods finds risky methods such as PUT,
+                  DELETE or TRACE
+  -script-config a YAML or JSON file of rules that import the output of NSE scripts
+                  as a note, an issue, or a tag, or ignore it
+  -scope         a file listing the addresses, CIDR ranges, and hostnames such as
+                  *.example.com that are in scope, one per line. Hosts outside it are
+                  never imported
+  -scope-action   what to do when an input has out of scope hosts, abort to fail the
+                  input with an error or skip to import the rest (default abort)
+  -include-cidr   only import hosts in an address or CIDR range, may be repeated or
+                  given a comma separated list
+  -exclude-cidr   do not import hosts in an address or CIDR range, may be repeated or
+                  given a comma separated list
+  -protocols      only import services using these protocols, a comma separated list
+                  of tcp, udp, sctp, and ip
+  -ports          only import services on these ports, such as 80,443,8000-9000
+  -exclude-ports  do not import services on these ports
+  -services       only import services with these names, a comma separated list where
+                  each name may be a regular expression such as http.*
+  -exclude-services
+                  do not import services with these names
+  -require-script only import hosts with output from one of these scripts, a comma
+                  separated list of script names and patterns such as 'smb-vuln-*'
+  -exclude-hostnames
+                  do not import hosts with a hostname matching this regular expression,
+                  such as '^dontscan\.'
+  -shared-mac-tag tag hosts that share their MAC address with other hosts as shared-mac,
+                  a warning is always logged for shared MAC addresses
+
+Exit status:
+  0  success
+  1  invalid options or another error
+  2  an input could not be read or parsed
+  3  no hosts were left to import after filtering
+  4  the API server rejected the credentials, or none were given
+  5  the API server could not be reached or the import failed
 `
 )
 
-func buildProject(run *nmap.NmapRun, projectID string, tags []string) (*lair.Project, error) {
-	project := &lair.Project{}
-	project.ID = projectID
-	project.Tool = tool
-	project.Commands = append(project.Commands, lair.Command{Tool: tool, Command: run.Args})
-
-	for _, h := range run.Hosts {
-		host := &lair.Host{Tags: tags}
-		if h.Status.State != "up" {
-			continue
+// saveMerged imports the project merged from every input, writes it to the
+// -o file, or compares it with the lair project for diff.
+func saveMerged(c *apiClient, dOpts *client.DOptions, opts *options) error {
+	if opts.diffWith != nil {
+		logDiff(opts.merged, opts.diffWith)
+		opts.summary.record(opts.merged)
+		return nil
+	}
+	if opts.outputFile != "" {
+		if err := writeProject(opts.outputFile, opts.merged); err != nil {
+			return err
 		}
+		opts.summary.record(opts.merged)
+		log.Printf("Success: %d hosts written to %s", len(opts.merged.Hosts), opts.outputFile)
+		return nil
+	}
+	if err := importProject(c, dOpts, opts.merged, opts); err != nil {
+		return err
+	}
+	log.Printf("Success: %d merged hosts imported successfully", len(opts.merged.Hosts))
+	return nil
+}
 
-		for _, address := range h.Addresses {
-			switch {
-			case address.AddrType == "ipv4":
-				host.IPv4 = address.Addr
-			case address.AddrType == "mac":
-				host.MAC = address.Addr
-			}
-		}
+// writeProject writes project as indented JSON to filename, or to standard
+// output when filename is "-".
+func writeProject(filename string, project *lair.Project) error {
+	data, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not marshal project. Error %s", err.Error())
+	}
+	data = append(data, '\n')
+	if filename == stdinName {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(filename, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write project. Error %s", err.Error())
+	}
+	return nil
+}
+
+// confirmImport asks for confirmation on the terminal before importing more
+// hosts than -confirm-over allows, unless -yes is set. Without a terminal
+// such imports fail.
+func confirmImport(project *lair.Project, opts *options) error {
+	if opts.confirmOver <= 0 || len(project.Hosts) <= opts.confirmOver || opts.yes {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("Importing %d hosts exceeds -confirm-over %d, use -yes to import them", len(project.Hosts), opts.confirmOver)
+	}
+	fmt.Fprintf(os.Stderr, "Import %d hosts into project %s? [y/N] ", len(project.Hosts), project.ID)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("Import of %d hosts was not confirmed", len(project.Hosts))
+}
 
-		for _, hostname := range h.Hostnames {
-			host.Hostnames = append(host.Hostnames, hostname.Name)
+// connect returns a client for the lair API server at lairURL, reading the
+// password from the -password-file when it is given.
+func connect(lairURL string, co *connOptions) (*apiClient, error) {
+	if lairURL == "" {
+		return nil, errors.New("Missing LAIR_API_SERVER environment variable")
+	}
+	pass := ""
+	if co.passwordFile != "" {
+		var err error
+		pass, err = readPassword(co.passwordFile)
+		if err != nil {
+			return nil, err
 		}
+	}
+	return newClient(lairURL, pass, co)
+}
 
-		for _, p := range h.Ports {
-			service := lair.Service{}
-			service.Port = p.PortId
-			service.Protocol = p.Protocol
+// newClient returns a client for the lair API server at lairURL. The
+// username and password are taken from -user and pass when given, then from
+// LAIR_USER and LAIR_PASSWORD, and then from the URL. A missing password is
+// prompted for when standard input is a terminal.
+func newClient(lairURL, pass string, co *connOptions) (*apiClient, error) {
+	u, err := url.Parse(lairURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing LAIR_API_SERVER URL. Error %s", err.Error())
+	}
+	user := co.user
+	if user == "" {
+		user = os.Getenv("LAIR_USER")
+	}
+	if pass == "" {
+		pass = os.Getenv("LAIR_PASSWORD")
+	}
+	if u.User != nil {
+		if user == "" {
+			user = u.User.Username()
+		}
+		if pass == "" {
+			pass, _ = u.User.Password()
+		}
+	}
+	if user != "" && pass == "" {
+		pass, err = promptPassword(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user == "" || pass == "" {
+		return nil, withExitCode(exitAuth, errors.New("Missing username and/or password"))
+	}
+	c, err := client.New(&client.COptions{
+		User:               user,
+		Password:           pass,
+		Host:               u.Host,
+		Scheme:             u.Scheme,
+		InsecureSkipVerify: co.insecureSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error setting up client. Error %s", err.Error())
+	}
+	httpClient, err := newHTTPClient(co)
+	if err != nil {
+		return nil, fmt.Errorf("Error setting up client. Error %s", err.Error())
+	}
+	return &apiClient{C: c, http: httpClient, retries: co.retries}, nil
+}
 
-			if p.State.State != "open" {
-				continue
-			}
+// promptPassword asks for the password of user on the terminal without
+// echoing it. It returns an empty password when standard input is not a
+// terminal.
+func promptPassword(user string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", nil
+	}
+	fmt.Fprintf(os.Stderr, "Password for %s: ", user)
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("Could not read password. Error %s", err.Error())
+	}
+	return string(pass), nil
+}
 
-			if p.Service.Name != "" {
-				service.Service = p.Service.Name
-				service.Product = "Unknown"
-				if p.Service.Product != "" {
-					service.Product = p.Service.Product
-					if p.Service.Version != "" {
-						service.Product += " " + p.Service.Version
-					}
-				}
-			}
+// readPassword reads a password from the first line of filename.
+func readPassword(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("Could not read password file. Error %s", err.Error())
+	}
+	pass := strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r")
+	if pass == "" {
+		return "", fmt.Errorf("Password file %s is empty", filename)
+	}
+	return pass, nil
+}
 
-			for _, script := range p.Scripts {
-				note := &lair.Note{Title: script.Id, Content: script.Output, LastModifiedBy: tool}
-				service.Notes = append(service.Notes, *note)
-			}
+// importFile parses a single scan file and imports it into the lair project.
+func importFile(c *apiClient, dOpts *client.DOptions, filename, projectID string, opts *options) error {
+	if opts.showProgress {
+		o := *opts
+		o.progress = newProgress(filename)
+		opts = &o
+	}
+	r, err := openInput(filename, opts)
+	if err != nil {
+		return withExitCode(exitParse, fmt.Errorf("Could not open file. Error %s", err.Error()))
+	}
+	defer r.Close()
+	br := bufio.NewReader(r)
+	if kind := archiveType(br); kind != "" {
+		return importArchive(c, dOpts, br, kind, filename, projectID, opts)
+	}
+	return importReader(c, dOpts, br, projectID, opts)
+}
 
-			host.Services = append(host.Services, service)
-		}
+// importReader parses scan data from r and imports it into the lair project.
+func importReader(c *apiClient, dOpts *client.DOptions, r io.Reader, projectID string, opts *options) error {
+	project, err := parseInput(r, projectID, opts)
+	if err != nil {
+		return withExitCode(exitParse, fmt.Errorf("Error parsing nmap. Error %s", err.Error()))
+	}
+	if opts.merged != nil {
+		mergeProject(opts.merged, project)
+		return nil
+	}
+	opts.progress.report(true)
+	if err := importProject(c, dOpts, project, opts); err != nil {
+		return err
+	}
+	opts.progress.addUploaded(len(project.Hosts))
+	opts.progress.report(true)
+	return nil
+}
 
-		if len(h.Os.OsMatches) > 0 {
-			os := lair.OS{}
-			os.Tool = tool
-			os.Weight = osWeight
-			os.Fingerprint = h.Os.OsMatches[0].Name
-			host.OS = os
+// importProject sends project to the lair API server. With -dry-run it only
+// logs what would be imported.
+func importProject(c *apiClient, dOpts *client.DOptions, project *lair.Project, opts *options) error {
+	if opts.dryRun {
+		if !opts.validate {
+			logDryRun(project)
 		}
-
-		project.Hosts = append(project.Hosts, *host)
-
+		opts.summary.record(project)
+		return nil
+	}
+	if err := confirmImport(project, opts); err != nil {
+		return err
 	}
+	return sendBatches(c, dOpts, splitProject(project, opts.batchSize), opts)
+}
 
-	return project, nil
+// sendProject sends project to the lair API server in a single request.
+func sendProject(c *apiClient, dOpts *client.DOptions, project *lair.Project) error {
+	debugf("Importing %s into project %s on %s://%s", statsOf(project), project.ID, c.Scheme, c.Host)
+	res, err := c.importProject(dOpts, project)
+	if err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Unable to import project. Error %s", err.Error()))
+	}
+	defer res.Body.Close()
+	debugf("API server returned %s, %d bytes", res.Status, res.ContentLength)
+	if apiExitCode(res.StatusCode) == exitAuth {
+		return withExitCode(exitAuth, fmt.Errorf("Import failed, server returned %s", res.Status))
+	}
+	droneRes := &client.Response{}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Error %s", err.Error()))
+	}
+	if err := json.Unmarshal(body, droneRes); err != nil {
+		return withExitCode(exitAPI, fmt.Errorf("Could not unmarshal JSON. Error %s", err.Error()))
+	}
+	if droneRes.Status == "Error" {
+		return withExitCode(exitAPI, fmt.Errorf("Import failed. Error %s", droneRes.Message))
+	}
+	return nil
 }
 
 func main() {
 	showVersion := flag.Bool("v", false, "")
 	insecureSSL := flag.Bool("k", false, "")
+	configFile := flag.String("config", "", "")
+	quiet := flag.Bool("quiet", false, "")
+	verbose := flag.Bool("verbose", false, "")
+	debug := flag.Bool("debug", false, "")
+	user := flag.String("user", "", "")
+	passwordFile := flag.String("password-file", "", "")
+	proxy := flag.String("proxy", "", "")
+	caCert := flag.String("ca-cert", "", "")
+	clientCert := flag.String("client-cert", "", "")
+	clientKey := flag.String("client-key", "", "")
+	connectTimeout := flag.Duration("connect-timeout", 30*time.Second, "")
+	timeout := flag.Duration("timeout", 0, "")
+	retries := flag.Int("retries", 0, "")
+	batchSize := flag.Int("batch-size", 0, "")
+	concurrency := flag.Int("concurrency", 1, "")
 	forcePorts := flag.Bool("force-ports", false, "")
+	onlyNew := flag.Bool("only-new", false, "")
+	dryRun := flag.Bool("dry-run", false, "")
+	confirmOver := flag.Int("confirm-over", 0, "")
+	yes := flag.Bool("yes", false, "")
+	outputFile := flag.String("o", "", "")
 	limitHosts := flag.Bool("limit-hosts", false, "")
+	skipGhosts := flag.Bool("skip-ghosts", false, "")
+	minPorts := flag.Int("min-ports", 0, "")
 	tags := flag.String("tags", "", "")
+	commandNote := flag.String("command-note", "", "")
+	ipMapFile := flag.String("ip-map", "", "")
+	tagRulesFile := flag.String("tag-rules", "", "")
+	serviceMapFile := flag.String("service-map", "", "")
+	serviceTagsFile := flag.String("service-tags", "", "")
+	format := flag.String("format", formatAuto, "")
+	merge := flag.Bool("merge", false, "")
+	recoverTruncated := flag.Bool("recover", false, "")
+	var inputHeaders stringList
+	flag.Var(&inputHeaders, "input-header", "")
+	inputList := flag.String("input-list", "", "")
+	maxInputSize := flag.String("max-input-size", "", "")
+	maxNoteSize := flag.String("max-note-size", "", "")
+	skipNoteSize := flag.String("skip-note-size", "", "")
+	redactFile := flag.String("redact", "", "")
+	maxDepth := flag.Int("max-xml-depth", defaultMaxXMLDepth, "")
+	showProgress := flag.Bool("progress", false, "")
+	osAccuracy := flag.Int("os-accuracy", 0, "")
+	osMatches := flag.Int("os-matches", 1, "")
+	osTags := flag.Bool("os-tags", false, "")
+	includeStates := flag.String("include-states", "", "")
+	minConf := flag.Int("min-conf", 0, "")
+	udpOpenFiltered := flag.Bool("udp-open-filtered", false, "")
+	redirectHostnames := flag.Bool("redirect-hostnames", false, "")
+	sharedMACTag := flag.Bool("shared-mac-tag", false, "")
+	httpMethodIssues := flag.Bool("http-method-issues", false, "")
+	scriptConfigFile := flag.String("script-config", "", "")
+	scopeFile := flag.String("scope", "", "")
+	scopeAction := flag.String("scope-action", "abort", "")
+	var includeCIDRs, excludeCIDRs stringList
+	flag.Var(&includeCIDRs, "include-cidr", "")
+	flag.Var(&excludeCIDRs, "exclude-cidr", "")
+	protocolList := flag.String("protocols", "", "")
+	portList := flag.String("ports", "", "")
+	excludePortList := flag.String("exclude-ports", "", "")
+	serviceList := flag.String("services", "", "")
+	excludeServiceList := flag.String("exclude-services", "", "")
+	excludeHostnames := flag.String("exclude-hostnames", "", "")
+	requireScript := flag.String("require-script", "", "")
 	flag.Usage = func() {
 		fmt.Println(usage)
 	}
-	flag.Parse()
+	command, args := parseCommand(flag.CommandLine, os.Args[1:])
 	if *showVersion {
 		log.Println(version)
 		os.Exit(0)
 	}
+	if err := loadConfig(*configFile); err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
+	}
+	switch {
+	case *debug:
+		setLogLevel(levelDebug, os.Stderr)
+	case *verbose:
+		setLogLevel(levelVerbose, os.Stderr)
+	case *quiet:
+		setLogLevel(levelQuiet, os.Stderr)
+	}
+	co := &connOptions{
+		user:         *user,
+		passwordFile: *passwordFile,
+		insecureSSL:  *insecureSSL,
+		proxy:        *proxy,
+		caCert:       *caCert,
+		clientCert:   *clientCert,
+		clientKey:    *clientKey,
+
+		connectTimeout: *connectTimeout,
+		timeout:        *timeout,
+		retries:        *retries,
+	}
+	switch command {
+	case commandValidate:
+		*dryRun = true
+	case commandConvert:
+		if *outputFile == "" {
+			*outputFile = stdinName
+		}
+	case commandProjects:
+		c, err := connect(os.Getenv("LAIR_API_SERVER"), co)
+		if err != nil {
+			fatal(err)
+		}
+		if err := listProjects(c); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	// A dry run or writing to a file only needs the API server to look up
+	// existing hosts.
+	needServer := (!*dryRun && *outputFile == "") || *onlyNew || command == commandDiff
 	lairURL := os.Getenv("LAIR_API_SERVER")
-	if lairURL == "" {
+	if lairURL == "" && needServer {
 		log.Fatal("Fatal: Missing LAIR_API_SERVER environment variable")
 	}
 	lairPID := os.Getenv("LAIR_ID")
 
-	var filename string
-	switch len(flag.Args()) {
-	case 2:
-		lairPID = flag.Arg(0)
-		filename = flag.Arg(1)
-	case 1:
-		filename = flag.Arg(0)
+	var inputs []string
+	var nmapArgs []string
+	switch {
+	case command == commandValidate || command == commandConvert:
+		inputs = args
+	case command == commandScan:
+		var err error
+		lairPID, nmapArgs, err = parseScanArgs(args, lairPID)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	case len(args) == 0:
+		if *inputList == "" {
+			log.Fatal("Fatal: Missing required argument")
+		}
+	case len(args) == 1 && (*inputList == "" || lairPID != ""):
+		inputs = args
+	case (lairPID != "" || !needServer) && inputExists(args[0]):
+		inputs = args
 	default:
-		log.Fatal("Fatal: Missing required argument")
+		lairPID = args[0]
+		inputs = args[1:]
 	}
-	if lairPID == "" {
+	if lairPID == "" && needServer {
 		log.Fatal("Fatal: Missing LAIR_ID")
 	}
-	u, err := url.Parse(lairURL)
+	var c *apiClient
+	if needServer {
+		var err error
+		c, err = connect(lairURL, co)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	// Tags from LAIR_TAGS are merged with -tags.
+	hostTags := []string{}
+	for _, list := range []string{os.Getenv("LAIR_TAGS"), *tags} {
+		for _, tag := range strings.Split(list, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				hostTags = appendUnique(hostTags, tag)
+			}
+		}
+	}
+	headers, err := parseHeaders(inputHeaders)
 	if err != nil {
-		log.Fatalf("Fatal: Error parsing LAIR_API_SERVER URL. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	if u.User == nil {
-		log.Fatal("Fatal: Missing username and/or password")
+	maxSize, err := parseSize(*maxInputSize)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	user := u.User.Username()
-	pass, _ := u.User.Password()
-	if user == "" || pass == "" {
-		log.Fatal("Fatal: Missing username and/or password")
+	noteSize, err := parseSize(*maxNoteSize)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	c, err := client.New(&client.COptions{
-		User:               user,
-		Password:           pass,
-		Host:               u.Host,
-		Scheme:             u.Scheme,
-		InsecureSkipVerify: *insecureSSL,
-	})
+	skipSize, err := parseSize(*skipNoteSize)
 	if err != nil {
-		log.Fatalf("Fatal: Error setting up client. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	data, err := ioutil.ReadFile(filename)
+	var redactions []*regexp.Regexp
+	if *redactFile != "" {
+		redactions, err = readRedactions(*redactFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	states, err := parseStates(*includeStates)
 	if err != nil {
-		log.Fatalf("Fatal: Could not open file. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	hostTags := []string{}
-	if *tags != "" {
-		hostTags = strings.Split(*tags, ",")
+	protocols, err := parseProtocols(*protocolList)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	nmapRun, err := nmap.Parse(data)
+	var scripts *scriptConfig
+	if *scriptConfigFile != "" {
+		scripts, err = readScriptConfig(*scriptConfigFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	includeNets, err := parseCIDRs(includeCIDRs)
 	if err != nil {
-		log.Fatalf("Fatal: Error parsing nmap. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	project, err := buildProject(nmapRun, lairPID, hostTags)
+	excludeNets, err := parseCIDRs(excludeCIDRs)
 	if err != nil {
-		log.Fatalf("Fatal: Error building project. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	res, err := c.ImportProject(&client.DOptions{ForcePorts: *forcePorts, LimitHosts: *limitHosts}, project)
+	ports, err := parsePorts(*portList)
 	if err != nil {
-		log.Fatalf("Fatal: Unable to import project. Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	defer res.Body.Close()
-	droneRes := &client.Response{}
-	body, err := ioutil.ReadAll(res.Body)
+	excludePorts, err := parsePorts(*excludePortList)
 	if err != nil {
-		log.Fatalf("Fatal: Error %s", err.Error())
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	if err := json.Unmarshal(body, droneRes); err != nil {
-		log.Fatalf("Fatal: Could not unmarshal JSON. Error %s", err.Error())
+	services, err := parseServices(*serviceList)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
 	}
-	if droneRes.Status == "Error" {
-		log.Fatalf("Fatal: Import failed. Error %s", droneRes.Message)
+	excludeServices, err := parseServices(*excludeServiceList)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
+	}
+	var hostScope *scope
+	if *scopeFile != "" {
+		if *scopeAction != "abort" && *scopeAction != "skip" {
+			log.Fatalf("Fatal: Invalid -scope-action %q, expected abort or skip", *scopeAction)
+		}
+		hostScope, err = readScope(*scopeFile, *scopeAction == "abort")
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	var ipMap []ipMapping
+	if *ipMapFile != "" {
+		ipMap, err = readIPMap(*ipMapFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	var tagRules []tagRule
+	if *tagRulesFile != "" {
+		tagRules, err = readTagRules(*tagRulesFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	var serviceMap map[string]string
+	if *serviceMapFile != "" {
+		serviceMap, err = readServiceMap(*serviceMapFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	var serviceTags []serviceTagRule
+	if *serviceTagsFile != "" {
+		serviceTags, err = readServiceTagRules(*serviceTagsFile)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+	}
+	requireScripts, err := parseScriptPatterns(*requireScript)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
+	}
+	var excludeHostnamesRe *regexp.Regexp
+	if *excludeHostnames != "" {
+		excludeHostnamesRe, err = regexp.Compile(*excludeHostnames)
+		if err != nil {
+			log.Fatalf("Fatal: Invalid -exclude-hostnames expression. Error %s", err.Error())
+		}
+	}
+	// Hosts are limited locally so those with host script output or an OS
+	// survive, which the API server would drop for having no ports.
+	dOpts := &client.DOptions{ForcePorts: *forcePorts}
+	opts := &options{
+		format:            *format,
+		recover:           *recoverTruncated,
+		headers:           headers,
+		maxInputSize:      maxSize,
+		maxNoteSize:       noteSize,
+		skipNoteSize:      skipSize,
+		redactions:        redactions,
+		maxDepth:          *maxDepth,
+		showProgress:      *showProgress,
+		tags:              hostTags,
+		commandNote:       strings.TrimSpace(*commandNote),
+		osMinAccuracy:     *osAccuracy,
+		osMatches:         *osMatches,
+		osTags:            *osTags,
+		states:            states,
+		protocols:         protocols,
+		udpOpenFiltered:   *udpOpenFiltered,
+		minConf:           *minConf,
+		redirectHostnames: *redirectHostnames,
+		sharedMACTag:      *sharedMACTag,
+		httpMethodIssues:  *httpMethodIssues,
+		scriptConfig:      scripts,
+		includeNets:       includeNets,
+		excludeNets:       excludeNets,
+		ports:             ports,
+		excludePorts:      excludePorts,
+		services:          services,
+		excludeServices:   excludeServices,
+		excludeHostnames:  excludeHostnamesRe,
+		minPorts:          *minPorts,
+		limitHosts:        *limitHosts,
+		batchSize:         *batchSize,
+		concurrency:       *concurrency,
+		dryRun:            *dryRun,
+		confirmOver:       *confirmOver,
+		yes:               *yes,
+		validate:          command == commandValidate,
+		outputFile:        *outputFile,
+		skipGhosts:        *skipGhosts,
+		requireScripts:    requireScripts,
+		scope:             hostScope,
+		ipMap:             ipMap,
+		tagRules:          tagRules,
+		serviceMap:        serviceMap,
+		serviceTags:       serviceTags,
+	}
+	if *onlyNew {
+		opts.existing, err = fetchExisting(c, lairPID)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if command == commandDiff {
+		opts.diffWith, err = fetchExisting(c, lairPID)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	opts.summary = newImportSummary()
+	// -confirm-over counts the hosts of every input, so they are all parsed
+	// before the first import.
+	confirm := opts.confirmOver > 0 && !opts.yes && !opts.dryRun
+	if *merge || *outputFile != "" || opts.diffWith != nil || confirm {
+		opts.merged = newProject(lairPID)
+	}
+	if command == commandScan {
+		if err := runScan(c, dOpts, lairPID, nmapArgs, opts); err != nil {
+			fatal(err)
+		}
+		if opts.merged != nil {
+			if err := saveMerged(c, dOpts, opts); err != nil {
+				fatal(err)
+			}
+		}
+		opts.summary.log(opts.imported())
+		checkEmpty(opts)
+		log.Println("Success: Operation completed successfully")
+		return
 	}
+	names, err := expandInputs(inputs)
+	if err != nil {
+		log.Fatalf("Fatal: %s", err.Error())
+	}
+	var files []inputFile
+	for _, name := range names {
+		files = append(files, inputFile{name: name})
+	}
+	if *inputList != "" {
+		entries, err := readManifest(*inputList)
+		if err != nil {
+			log.Fatalf("Fatal: %s", err.Error())
+		}
+		files = append(files, entries...)
+	}
+	if len(files) == 0 {
+		log.Fatal("Fatal: No inputs to import")
+	}
+	failed := 0
+	var failure error
+	for _, f := range files {
+		fileOpts := opts
+		if len(f.tags) > 0 {
+			o := *opts
+			o.tags = append(append([]string{}, opts.tags...), f.tags...)
+			fileOpts = &o
+		}
+		if err := importFile(c, dOpts, f.name, lairPID, fileOpts); err != nil {
+			log.Printf("Error: %s: %s", f.name, err.Error())
+			if failure == nil {
+				failure = err
+			}
+			failed++
+			continue
+		}
+		log.Printf("Success: %s %s successfully", f.name, opts.imported())
+	}
+	if opts.merged != nil && failed < len(files) {
+		if err := saveMerged(c, dOpts, opts); err != nil {
+			fatal(err)
+		}
+	}
+	log.Printf("Summary: %d of %d files %s, %d failed", len(files)-failed, len(files), opts.imported(), failed)
+	opts.summary.log(opts.imported())
+	if failed > 0 {
+		fatal(withExitCode(exitCode(failure), errors.New("One or more files failed to import")))
+	}
+	checkEmpty(opts)
 	log.Println("Success: Operation completed successfully")
 }
+
+// checkEmpty exits with exitEmpty when no hosts were imported, such as when
+// every host was filtered out.
+func checkEmpty(opts *options) {
+	if opts.summary.totals.hosts == 0 {
+		fatal(withExitCode(exitEmpty, fmt.Errorf("No hosts were %s, %d were skipped", opts.imported(), opts.summary.skipped)))
+	}
+}
+
+// stringList is a flag.Value that collects every occurrence of a repeatable
+// flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
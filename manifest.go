@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputFile is a single input to import along with tags that apply only to
+// the hosts it contains.
+type inputFile struct {
+	name string
+	tags []string
+}
+
+// readManifest reads an input list. Each line holds an input, optionally
+// followed by whitespace and a comma separated list of tags for the hosts in
+// that input. Blank lines and lines starting with # are ignored. Relative
+// paths are resolved against the directory of the manifest, and directories
+// and glob patterns are expanded as they are on the command line.
+func readManifest(path string) ([]inputFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open input list. Error %s", err.Error())
+	}
+	defer f.Close()
+	dir := filepath.Dir(path)
+	var files []inputFile
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("Invalid input list entry on line %d", line)
+		}
+		name := fields[0]
+		if !isRemote(name) && name != stdinName && !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		var tags []string
+		if len(fields) == 2 {
+			tags = strings.Split(fields[1], ",")
+		}
+		names, err := expandInputs([]string{name})
+		if err != nil {
+			return nil, fmt.Errorf("Input list line %d: %s", line, err.Error())
+		}
+		for _, n := range names {
+			files = append(files, inputFile{name: n, tags: tags})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+const masscan = "masscan"
+
+type masscanRecord struct {
+	IP    string        `json:"ip"`
+	Ports []masscanPort `json:"ports"`
+}
+
+type masscanPort struct {
+	Port    int    `json:"port"`
+	Proto   string `json:"proto"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	TTL     int    `json:"ttl"`
+	Service struct {
+		Name   string `json:"name"`
+		Banner string `json:"banner"`
+	} `json:"service"`
+}
+
+// parseMasscanJSON parses masscan -oJ output. Masscan writes one record per
+// line wrapped in a JSON array, and older versions emit a trailing
+// "{finished: 1}" record that is not valid JSON, so records are decoded one
+// line at a time.
+func parseMasscanJSON(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(masscan)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		text = strings.TrimLeft(text, "[,")
+		text = strings.TrimRight(text, "],")
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "{finished") {
+			continue
+		}
+		record := masscanRecord{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("Invalid masscan JSON on line %d. Error %s", line, err.Error())
+		}
+		for _, mp := range record.Ports {
+			p := set.port(record.IP, mp.Proto, mp.Port)
+			if mp.Status != "" {
+				p.State = nmap.State{State: mp.Status, Reason: mp.Reason, ReasonTTL: float32(mp.TTL)}
+			}
+			if mp.Service.Name != "" {
+				p.Service.Name = mp.Service.Name
+			}
+			if mp.Service.Banner != "" {
+				p.Scripts = append(p.Scripts, nmap.Script{Id: "banner", Output: mp.Service.Banner})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
+
+// parseMasscanList parses masscan -oL output, which has lines in the form
+// "open tcp 80 10.0.0.1 1600000000" and optionally
+// "banner tcp 80 10.0.0.1 1600000000 http Server: nginx".
+func parseMasscanList(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(masscan)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 7)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("Invalid masscan list entry on line %d", line)
+		}
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid port on line %d. Error %s", line, err.Error())
+		}
+		p := set.port(fields[3], fields[1], id)
+		switch fields[0] {
+		case "banner":
+			if len(fields) > 5 {
+				p.Service.Name = fields[5]
+			}
+			if len(fields) > 6 {
+				p.Scripts = append(p.Scripts, nmap.Script{Id: "banner", Output: fields[6]})
+			}
+		default:
+			p.State.State = fields[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
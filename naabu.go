@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+const naabu = "naabu"
+
+type naabuRecord struct {
+	Host     string `json:"host"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	TLS      bool   `json:"tls"`
+}
+
+// parseNaabu parses naabu -json output, which contains one JSON object per
+// line for every open host and port pair.
+func parseNaabu(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(naabu)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		record := naabuRecord{}
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("Invalid naabu JSON on line %d. Error %s", line, err.Error())
+		}
+		ip := record.IP
+		if ip == "" {
+			ip = record.Host
+		}
+		if ip == "" || record.Port == 0 {
+			continue
+		}
+		protocol := record.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		p := set.port(ip, protocol, record.Port)
+		p.State.State = "open"
+		if record.TLS {
+			p.Service.Tunnel = "ssl"
+		}
+		if record.Host != "" && record.Host != ip {
+			h := set.host(ip)
+			found := false
+			for _, hostname := range h.Hostnames {
+				if hostname.Name == record.Host {
+					found = true
+				}
+			}
+			if !found {
+				h.Hostnames = append(h.Hostnames, nmap.Hostname{Name: record.Host, Type: "user"})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
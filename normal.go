@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+var (
+	normalReportRe = regexp.MustCompile(`^Nmap scan report for (?:(\S+) \(([^)]+)\)|(\S+))$`)
+	normalPortRe   = regexp.MustCompile(`^(\d+)/(\w+)\s+(\S+)\s+(\S+)(?:\s+(.*))?$`)
+	normalScriptRe = regexp.MustCompile(`^\|_?\s?([\w.-]+):\s?(.*)$`)
+	normalMACRe    = regexp.MustCompile(`^MAC Address: (\S+)(?: \((.*)\))?$`)
+	normalIgnRe    = regexp.MustCompile(`^Not shown: (\d+) (\S+)`)
+)
+
+// parseNormal is a best-effort parser for nmap normal (-oN) output. It
+// recovers addresses, hostnames, ports with their service columns, script
+// output, MAC addresses, and OS details.
+func parseNormal(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(tool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var ip string
+	var script *nmap.Script
+	hostScripts := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if strings.HasPrefix(line, "#") {
+			if m := gnmapArgsRe.FindStringSubmatch(line); m != nil {
+				set.run.Version = m[1]
+				set.run.Args = m[2]
+			}
+			continue
+		}
+		if m := normalReportRe.FindStringSubmatch(line); m != nil {
+			script = nil
+			hostScripts = false
+			if m[3] != "" {
+				ip = m[3]
+				set.host(ip)
+				continue
+			}
+			ip = m[2]
+			h := set.host(ip)
+			h.Hostnames = append(h.Hostnames, nmap.Hostname{Name: m[1]})
+			continue
+		}
+		if ip == "" {
+			continue
+		}
+		h := set.host(ip)
+		switch {
+		case strings.HasPrefix(line, "Host is down"):
+			h.Status.State = "down"
+		case line == "Host script results:":
+			hostScripts = true
+			script = nil
+		case strings.HasPrefix(line, "|"):
+			// A script starts on the first | line after a port or host
+			// line, or after the |_ line ending the previous script. Other
+			// lines continue its output, even when they look like
+			// "| Issuer: ...".
+			if script == nil {
+				if m := normalScriptRe.FindStringSubmatch(line); m != nil {
+					s := nmap.Script{Id: m[1], Output: m[2]}
+					if hostScripts {
+						h.HostScripts = append(h.HostScripts, s)
+						script = &h.HostScripts[len(h.HostScripts)-1]
+					} else if len(h.Ports) > 0 {
+						p := &h.Ports[len(h.Ports)-1]
+						p.Scripts = append(p.Scripts, s)
+						script = &p.Scripts[len(p.Scripts)-1]
+					}
+				}
+			} else {
+				text := strings.TrimPrefix(line, "|_")
+				if text == line {
+					text = strings.TrimPrefix(strings.TrimPrefix(line, "|"), " ")
+				}
+				script.Output += "\n" + text
+			}
+			if strings.HasPrefix(line, "|_") {
+				script = nil
+			}
+		default:
+			script = nil
+			if m := normalPortRe.FindStringSubmatch(line); m != nil {
+				id, err := strconv.Atoi(m[1])
+				if err != nil {
+					continue
+				}
+				p := nmap.Port{PortId: id, Protocol: m[2], State: nmap.State{State: m[3]}}
+				name := m[4]
+				if strings.HasPrefix(name, "ssl/") {
+					p.Service.Tunnel = "ssl"
+					name = strings.TrimPrefix(name, "ssl/")
+				}
+				p.Service.Name = strings.TrimSuffix(name, "?")
+				p.Service.Product = m[5]
+				h.Ports = append(h.Ports, p)
+			} else if m := normalMACRe.FindStringSubmatch(line); m != nil {
+				h.Addresses = append(h.Addresses, nmap.Address{Addr: m[1], AddrType: "mac", Vendor: m[2]})
+			} else if m := normalIgnRe.FindStringSubmatch(line); m != nil {
+				count, _ := strconv.Atoi(m[1])
+				h.ExtraPorts = append(h.ExtraPorts, nmap.ExtraPorts{State: m[2], Count: count})
+			} else if strings.HasPrefix(line, "OS details: ") {
+				for _, name := range strings.Split(strings.TrimPrefix(line, "OS details: "), ", ") {
+					h.Os.OsMatches = append(h.Os.OsMatches, nmap.OsMatch{Name: name})
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// osMatch is an nmap OS match with its accuracy as a percentage.
+type osMatch struct {
+	name     string
+	accuracy int
+	cpes     []string
+	classes  []nmap.OsClass
+}
+
+// osFromMatches returns the most accurate OS match that meets the minimum
+// accuracy. Its weight is osWeight scaled by the accuracy of the match. When
+// more than one match is requested the candidates are listed in a host note,
+// and the CPEs of the chosen match are recorded in an "OS CPE" host note.
+func (b *hostBuilder) osFromMatches(osMatches []nmap.OsMatch) (lair.OS, bool) {
+	var matches []osMatch
+	for _, m := range osMatches {
+		accuracy, err := strconv.Atoi(m.Accuracy)
+		if err != nil {
+			accuracy = 100
+		}
+		if accuracy < b.opts.osMinAccuracy {
+			continue
+		}
+		match := osMatch{name: m.Name, accuracy: accuracy, classes: m.OsClasses}
+		for _, class := range m.OsClasses {
+			match.cpes = appendUnique(match.cpes, cpeStrings(class.CPEs)...)
+		}
+		matches = append(matches, match)
+	}
+	if len(matches) == 0 {
+		return lair.OS{}, false
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].accuracy > matches[j].accuracy
+	})
+
+	if b.opts.osMatches > 1 {
+		var lines []string
+		for i, m := range matches {
+			if i == b.opts.osMatches {
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s (%d%%)", m.name, m.accuracy))
+		}
+		b.addNote(nil, "OS matches", strings.Join(lines, "\n"))
+	}
+
+	if b.opts.osTags {
+		b.addOSTags(matches[0].classes)
+	}
+
+	if len(matches[0].cpes) > 0 {
+		b.addNote(nil, "OS CPE", strings.Join(matches[0].cpes, "\n"))
+	}
+
+	return lair.OS{
+		Tool:        tool,
+		Weight:      osWeight * matches[0].accuracy / 100,
+		Fingerprint: matches[0].name,
+	}, true
+}
+
+// addOSTags tags the host with the OS family and, for anything other than a
+// general purpose computer, the device type of classes, such as os:windows
+// and device:printer.
+func (b *hostBuilder) addOSTags(classes []nmap.OsClass) {
+	for _, class := range classes {
+		if class.OsFamily != "" {
+			b.host.Tags = appendUnique(b.host.Tags, "os:"+tagValue(class.OsFamily))
+		}
+		if class.Type != "" && class.Type != "general purpose" {
+			b.host.Tags = appendUnique(b.host.Tags, "device:"+tagValue(class.Type))
+		}
+	}
+}
+
+// tagValue lowercases s and replaces spaces so it can be used in a tag.
+func tagValue(s string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(s)), " ", "-", -1)
+}
+
+// cpeStrings converts nmap CPEs to strings.
+func cpeStrings(cpes []nmap.CPE) []string {
+	var values []string
+	for _, cpe := range cpes {
+		values = append(values, string(cpe))
+	}
+	return values
+}
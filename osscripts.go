@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// Weights of operating systems identified by scripts. The one with the most
+// weight is used, comparing against nmap's fingerprinting scaled by its
+// accuracy. The OS reported over SMB comes from the host itself and is
+// trusted over any nmap match, the version reported in NTLM challenges only
+// identifies the Windows release, and SQL Server only hints that the host
+// runs Windows.
+const (
+	smbOSWeight   = 60
+	ntlmOSWeight  = 40
+	mssqlOSWeight = 20
+)
+
+// mapSMBOSDiscovery records the operating system and adds the FQDN reported
+// by smb-os-discovery. The output is still imported as a note.
+func mapSMBOSDiscovery(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	name := scriptElem(script, "os")
+	if name == "" {
+		name = outputField(script.Output, "OS")
+		if i := strings.Index(name, " ("); i > 0 {
+			name = name[:i]
+		}
+	}
+	if name != "" {
+		b.setScriptOS(name, smbOSWeight)
+	}
+	fqdn := scriptElem(script, "fqdn")
+	if fqdn == "" {
+		fqdn = outputField(script.Output, "FQDN")
+	}
+	b.addScriptHostname(fqdn, "")
+	return false
+}
+
+// mapNTLMInfo records the Windows version and adds the DNS name of the host
+// reported by rdp-ntlm-info and the other *-ntlm-info scripts. The output is
+// still imported as a note.
+func mapNTLMInfo(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	field := func(key string) string {
+		if value := scriptElem(script, key); value != "" {
+			return value
+		}
+		return outputField(script.Output, key)
+	}
+	if version := field("Product_Version"); version != "" {
+		b.setScriptOS("Microsoft Windows "+version, ntlmOSWeight)
+	}
+	b.addScriptHostname(field("DNS_Computer_Name"), "")
+	return false
+}
+
+// mapMSSQLInfo uses the SQL Server version reported by ms-sql-info as the
+// product of the service when nmap did not identify it, and records that the
+// host runs Windows. The output is still imported as a note.
+func mapMSSQLInfo(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	name := outputField(script.Output, "name")
+	if name == "" {
+		return false
+	}
+	if service != nil && (service.Product == "" || service.Product == "Unknown") {
+		service.Product = name
+		if number := outputField(script.Output, "number"); number != "" {
+			service.Product += " " + number
+		}
+	}
+	if !strings.Contains(strings.ToLower(script.Output), "linux") {
+		b.setScriptOS("Microsoft Windows", mssqlOSWeight)
+	}
+	return false
+}
+
+// setScriptOS records an operating system identified by a script. It is used
+// when nmap's OS detection did not match the host, and the highest weighted
+// script result wins.
+func (b *hostBuilder) setScriptOS(fingerprint string, weight int) {
+	if b.scriptOS != nil && b.scriptOS.Weight >= weight {
+		return
+	}
+	b.scriptOS = &lair.OS{Tool: tool, Weight: weight, Fingerprint: fingerprint}
+}
+
+// scriptElem returns the value of the top level element with key in script.
+func scriptElem(script *nmap.Script, key string) string {
+	for _, e := range script.Elements {
+		if e.Key == key {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	return ""
+}
+
+// outputField returns the value of a "Name: value" line in script output.
+func outputField(output, name string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, name+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, name+":"))
+		}
+	}
+	return ""
+}
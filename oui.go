@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// macPrefixDirs are searched for nmap's nmap-mac-prefixes file after
+// NMAPDIR.
+var macPrefixDirs = []string{
+	"/usr/share/nmap",
+	"/usr/local/share/nmap",
+	"/opt/homebrew/share/nmap",
+	`C:\Program Files (x86)\Nmap`,
+	`C:\Program Files\Nmap`,
+}
+
+// embeddedMACPrefixes holds the IEEE MA-L registry in the format of nmap's
+// nmap-mac-prefixes file, so vendors can be looked up without nmap
+// installed.
+//
+//go:embed mac-prefixes.txt
+var embeddedMACPrefixes string
+
+var (
+	macPrefixesOnce sync.Once
+	macPrefixes     map[string]string
+	ouiVendors      map[string]string
+)
+
+// readMACPrefixes reads vendors in the format of nmap-mac-prefixes, keyed by
+// the uppercase hex digits of their prefix.
+func readMACPrefixes(r io.Reader) (map[string]string, error) {
+	prefixes := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		prefixes[strings.ToUpper(fields[0])] = strings.TrimSpace(fields[1])
+	}
+	return prefixes, scanner.Err()
+}
+
+// loadMACPrefixes reads the vendors in the nmap-mac-prefixes file of the
+// local nmap install, which overrides the embedded registry. It returns nil
+// when no file is found.
+func loadMACPrefixes() map[string]string {
+	dirs := macPrefixDirs
+	if dir := os.Getenv("NMAPDIR"); dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		f, err := os.Open(filepath.Join(dir, "nmap-mac-prefixes"))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		prefixes, err := readMACPrefixes(f)
+		if err != nil {
+			log.Printf("Warning: Could not read %s. Error %s", f.Name(), err.Error())
+			return nil
+		}
+		debugf("Read %d MAC prefixes from %s", len(prefixes), f.Name())
+		return prefixes
+	}
+	return nil
+}
+
+// macVendor returns the vendor of mac from nmap's nmap-mac-prefixes file,
+// matching the longest prefix as nmap does, or else from the embedded
+// registry.
+func macVendor(mac string) string {
+	mac = strings.ToUpper(strings.Replace(mac, "-", ":", -1))
+	if len(mac) < 8 {
+		return ""
+	}
+	if strings.HasPrefix(mac, "02:42:") {
+		return "Docker"
+	}
+	macPrefixesOnce.Do(func() {
+		macPrefixes = loadMACPrefixes()
+		ouiVendors, _ = readMACPrefixes(strings.NewReader(embeddedMACPrefixes))
+	})
+	digits := strings.Replace(mac, ":", "", -1)
+	for _, n := range []int{9, 7, 6} {
+		if len(digits) >= n {
+			if vendor, ok := macPrefixes[digits[:n]]; ok {
+				return vendor
+			}
+		}
+	}
+	return ouiVendors[digits[:6]]
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// portStates lists the port states nmap reports.
+var portStates = []string{"open", "closed", "filtered", "unfiltered", "open|filtered", "closed|filtered"}
+
+// portProtocols lists the port protocols nmap reports.
+var portProtocols = []string{"tcp", "udp", "sctp", "ip"}
+
+// sslServices maps services to the name they are known by when tunneled
+// over SSL.
+var sslServices = map[string]string{
+	"ftp":  "ftps",
+	"http": "https",
+	"imap": "imaps",
+	"ldap": "ldaps",
+	"pop3": "pop3s",
+	"smtp": "smtps",
+}
+
+// serviceName returns the name to import for service. Services nmap found
+// tunneled over SSL are given their SSL name, such as https, or are prefixed
+// with ssl/ as in nmap's normal output.
+func serviceName(service *nmap.Service) string {
+	if service.Tunnel != "ssl" {
+		return service.Name
+	}
+	if name, ok := sslServices[service.Name]; ok {
+		return name
+	}
+	return "ssl/" + service.Name
+}
+
+// addOwner records the user running a service, as reported by an ident
+// scan with -I, as a service note.
+func (b *hostBuilder) addOwner(service *lair.Service, owner *nmap.Owner) {
+	if owner.Name != "" {
+		b.addNote(service, "Owner", owner.Name)
+	}
+}
+
+// addServiceInfo records the OS and device type nmap reported for service
+// as a service note, in the form of nmap's "Service Info" line.
+func (b *hostBuilder) addServiceInfo(service *lair.Service, info *nmap.Service) {
+	var fields []string
+	if info.OsType != "" {
+		fields = append(fields, "OS: "+info.OsType)
+	}
+	if info.DeviceType != "" {
+		fields = append(fields, "Device: "+info.DeviceType)
+	}
+	if len(fields) > 0 {
+		b.addNote(service, "Service info", strings.Join(fields, "; "))
+	}
+}
+
+// maxBannerLength is the longest banner used as a service product.
+const maxBannerLength = 80
+
+// scriptBanner returns the first line of the output of the banner script in
+// scripts, for use as the product of a service nmap could not identify.
+func scriptBanner(scripts []nmap.Script) string {
+	for _, script := range scripts {
+		if script.Id != "banner" {
+			continue
+		}
+		banner := strings.TrimSpace(script.Output)
+		// The banner script escapes line endings as \x0D\x0A.
+		for _, sep := range []string{"\n", "\r", `\x0D`, `\x0A`} {
+			if i := strings.Index(banner, sep); i >= 0 {
+				banner = banner[:i]
+			}
+		}
+		banner = strings.TrimSpace(banner)
+		if len(banner) > maxBannerLength {
+			banner = banner[:maxBannerLength]
+		}
+		return banner
+	}
+	return ""
+}
+
+// confident reports whether nmap's confidence in the detection of service
+// meets the minimum. Services without a confidence, such as those from
+// grepable output, are always accepted.
+func (o *options) confident(service *nmap.Service) bool {
+	return service.Configuration == 0 || service.Configuration >= o.minConf
+}
+
+// parseStates converts a comma separated list of port states into a set.
+func parseStates(list string) (map[string]bool, error) {
+	states := map[string]bool{}
+	if list == "" {
+		return states, nil
+	}
+	for _, state := range strings.Split(list, ",") {
+		state = strings.ToLower(strings.TrimSpace(state))
+		valid := false
+		for _, s := range portStates {
+			if s == state {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("Invalid port state %q, expected one of %s", state, strings.Join(portStates, ", "))
+		}
+		states[state] = true
+	}
+	return states, nil
+}
+
+// parseProtocols converts a comma separated list of port protocols into a
+// set. An empty list returns nil, which includes every protocol.
+func parseProtocols(list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	protocols := map[string]bool{}
+	for _, protocol := range strings.Split(list, ",") {
+		protocol = strings.ToLower(strings.TrimSpace(protocol))
+		valid := false
+		for _, p := range portProtocols {
+			if p == protocol {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("Invalid protocol %q, expected one of %s", protocol, strings.Join(portProtocols, ", "))
+		}
+		protocols[protocol] = true
+	}
+	return protocols, nil
+}
+
+// includesPort reports whether p is imported. Ports using a protocol not
+// given with -protocols, or outside the -ports and -exclude-ports filters,
+// are skipped. Otherwise open ports are always
+// imported, and open|filtered UDP ports with -udp-open-filtered.
+func (o *options) includesPort(p *nmap.Port) bool {
+	if o.protocols != nil && !o.protocols[p.Protocol] {
+		return false
+	}
+	if len(o.ports) > 0 && !containsPort(o.ports, p.PortId) {
+		return false
+	}
+	if containsPort(o.excludePorts, p.PortId) {
+		return false
+	}
+	state := p.State.State
+	if o.udpOpenFiltered && p.Protocol == "udp" && state == "open|filtered" {
+		return true
+	}
+	return state == "open" || o.states[state]
+}
+
+// addPortState records the state of a port and the reason nmap gave for it
+// as a service note. Open ports are only noted when a reason is known.
+func (b *hostBuilder) addPortState(service *lair.Service, state *nmap.State) {
+	if state.Reason == "" && state.State == "open" {
+		return
+	}
+	lines := []string{"State: " + state.State}
+	if state.Reason != "" {
+		lines = append(lines, "Reason: "+state.Reason)
+		lines = append(lines, fmt.Sprintf("Reason TTL: %d", int(state.ReasonTTL)))
+	}
+	if state.ReasonIP != "" {
+		lines = append(lines, "Reason IP: "+state.ReasonIP)
+	}
+	if state.State == "open|filtered" {
+		lines = append(lines, "Nmap received no response, so the port may be filtered rather than open.")
+	}
+	b.addNote(service, "Port state", strings.Join(lines, "\n"))
+}
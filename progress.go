@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often progress is logged.
+const progressInterval = 2 * time.Second
+
+// progress tracks and periodically logs how far the parsing and importing
+// of an input has got. All methods are safe to call on a nil *progress,
+// which does nothing, and from multiple goroutines.
+type progress struct {
+	mu       sync.Mutex
+	name     string
+	total    int64
+	read     int64
+	parsed   int
+	uploaded int
+	start    time.Time
+	last     time.Time
+}
+
+func newProgress(name string) *progress {
+	now := time.Now()
+	return &progress{name: name, start: now, last: now}
+}
+
+// wrap returns a reader that counts the bytes read from r towards the
+// progress. total is the expected number of bytes, or zero if unknown.
+func (p *progress) wrap(r io.Reader, total int64) io.Reader {
+	if p == nil {
+		return r
+	}
+	p.mu.Lock()
+	p.total = total
+	p.mu.Unlock()
+	return &progressReader{r: r, p: p}
+}
+
+// addParsed records n more hosts parsed.
+func (p *progress) addParsed(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.parsed += n
+	p.mu.Unlock()
+	p.report(false)
+}
+
+// addUploaded records n more hosts uploaded to the API server.
+func (p *progress) addUploaded(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.uploaded += n
+	p.mu.Unlock()
+	p.report(false)
+}
+
+// report logs the current progress if force is true or progressInterval
+// has passed since the last report.
+func (p *progress) report(force bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if !force && now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+	status := fmt.Sprintf("%d hosts parsed, %d uploaded", p.parsed, p.uploaded)
+	if p.total > 0 && p.read > 0 {
+		percent := float64(p.read) / float64(p.total) * 100
+		elapsed := now.Sub(p.start)
+		eta := time.Duration(float64(elapsed) * float64(p.total-p.read) / float64(p.read))
+		status += fmt.Sprintf(", %.1f%% read, ETA %s", percent, eta.Round(time.Second))
+	} else if p.read > 0 {
+		status += fmt.Sprintf(", %d bytes read", p.read)
+	}
+	log.Printf("Progress: %s: %s", p.name, status)
+}
+
+// progressReader counts bytes read towards a progress.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.p.mu.Lock()
+	pr.p.read += int64(n)
+	pr.p.mu.Unlock()
+	pr.p.report(false)
+	return n, err
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// options controls how scan data is parsed and converted into lair hosts.
+type options struct {
+	format       string
+	recover      bool
+	headers      http.Header
+	maxInputSize int64
+	maxDepth     int
+	maxNoteSize  int64
+	skipNoteSize int64
+	redactions   []*regexp.Regexp
+	showProgress bool
+	tags         []string
+	commandNote  string
+
+	// osMinAccuracy is the lowest accuracy of an OS match that is imported,
+	// osMatches the number of matches to list in a host note, and osTags
+	// tags hosts with the OS family and device type of the match.
+	osMinAccuracy int
+	osMatches     int
+	osTags        bool
+
+	// minConf is the lowest service detection confidence for which the
+	// service name and product are imported.
+	minConf int
+
+	// redirectHostnames adds the hosts that http-title followed redirects
+	// to as hostnames.
+	redirectHostnames bool
+
+	// httpMethodIssues raises an issue for risky methods found by
+	// http-methods.
+	httpMethodIssues bool
+
+	// includeNets and excludeNets restrict the hosts that are imported by
+	// address.
+	includeNets []*net.IPNet
+	excludeNets []*net.IPNet
+
+	// ports and excludePorts restrict the services that are imported by
+	// port number.
+	ports        []portRange
+	excludePorts []portRange
+
+	// services and excludeServices restrict the services that are imported
+	// by name.
+	services        *regexp.Regexp
+	excludeServices *regexp.Regexp
+
+	// scope holds the addresses and hostnames hosts must match.
+	scope *scope
+
+	// ipMap rewrites scanned addresses to the real address of the host.
+	ipMap []ipMapping
+
+	// tagRules tags hosts by address range.
+	tagRules []tagRule
+
+	// serviceMap renames services.
+	serviceMap map[string]string
+
+	// serviceTags tags services by name.
+	serviceTags []serviceTagRule
+
+	// requireScripts holds script id patterns, one of which a host must have
+	// output for to be imported.
+	requireScripts []string
+
+	// skipGhosts skips hosts only marked up by -Pn.
+	skipGhosts bool
+
+	// limitHosts skips hosts without services, host script output, or an
+	// OS.
+	limitHosts bool
+
+	// minPorts skips hosts with fewer imported services.
+	minPorts int
+
+	// excludeHostnames skips hosts with a matching hostname.
+	excludeHostnames *regexp.Regexp
+
+	// scriptConfig holds the rules from a script mapping file.
+	scriptConfig *scriptConfig
+
+	// sharedMACTag tags hosts that share a MAC address with another host.
+	sharedMACTag bool
+
+	// protocols holds the port protocols imported, or nil for all.
+	protocols map[string]bool
+
+	// states holds the port states imported in addition to open, and
+	// udpOpenFiltered imports open|filtered UDP ports.
+	states          map[string]bool
+	udpOpenFiltered bool
+
+	// dryRun logs what would be imported instead of importing it.
+	dryRun bool
+
+	// confirmOver is the most hosts imported at once without confirmation,
+	// unless yes is set.
+	confirmOver int
+	yes         bool
+
+	// batchSize is the most hosts sent to the API server in one request,
+	// and concurrency is how many of those requests are sent at once.
+	batchSize   int
+	concurrency int
+
+	// validate only parses the inputs, for the validate command.
+	validate bool
+
+	// outputFile is where the merged project is written instead of being
+	// imported.
+	outputFile string
+
+	// diffWith holds the hosts in the lair project to compare the inputs
+	// with, for the diff command.
+	diffWith *existingHosts
+
+	// existing holds the hosts already in the lair project when -only-new
+	// is set.
+	existing *existingHosts
+
+	// merged collects the projects parsed from every input when -merge or
+	// -o is set, so they are imported or written together once all inputs
+	// are parsed.
+	merged *lair.Project
+
+	// summary totals what was imported from every input.
+	summary *importSummary
+
+	// progress is set per input when progress reporting is enabled.
+	progress *progress
+}
+
+// imported describes what happens to a successfully parsed input.
+func (o *options) imported() string {
+	if o.validate {
+		return "validated"
+	}
+	if o.merged != nil || o.dryRun {
+		return "parsed"
+	}
+	return "imported"
+}
+
+// newProject returns an empty lair project for projectID.
+func newProject(projectID string) *lair.Project {
+	return &lair.Project{ID: projectID, Tool: tool}
+}
+
+// addCommand records the command line of run in project, along with the
+// -command-note and details of the run. Scanners that do not record their
+// arguments are skipped.
+func addCommand(project *lair.Project, run *nmap.NmapRun, opts *options) {
+	if run.Args == "" {
+		return
+	}
+	scanner := tool
+	if run.Scanner != "" {
+		scanner = run.Scanner
+	}
+	command := run.Args
+	details := runDetails(run)
+	if opts.commandNote != "" {
+		details = append([]string{opts.commandNote}, details...)
+	}
+	if len(details) > 0 {
+		command += " (" + strings.Join(details, ", ") + ")"
+	}
+	project.Commands = append(project.Commands, lair.Command{Tool: scanner, Command: command})
+}
+
+// runDetails describes the provenance of run: the scanner version, the scan
+// type, when it started and finished, and how many hosts were up.
+func runDetails(run *nmap.NmapRun) []string {
+	var details []string
+	if run.Version != "" {
+		details = append(details, "version "+run.Version)
+	}
+	if run.ScanInfo.Type != "" {
+		details = append(details, fmt.Sprintf("%s scan of %d %s ports", run.ScanInfo.Type, run.ScanInfo.NumServices, run.ScanInfo.Protocol))
+	}
+	if start := time.Time(run.Start); start.Unix() > 0 {
+		details = append(details, "started "+start.UTC().Format(time.RFC3339))
+	}
+	finished := run.RunStats.Finished
+	if end := time.Time(finished.Time); end.Unix() > 0 {
+		details = append(details, "finished "+end.UTC().Format(time.RFC3339))
+	}
+	if finished.Elapsed > 0 {
+		details = append(details, fmt.Sprintf("%.0fs elapsed", finished.Elapsed))
+	}
+	if hosts := run.RunStats.Hosts; hosts.Total > 0 {
+		details = append(details, fmt.Sprintf("%d hosts up, %d down", hosts.Up, hosts.Down))
+	}
+	return details
+}
+
+func buildProject(run *nmap.NmapRun, projectID string, opts *options) (*lair.Project, error) {
+	project := newProject(projectID)
+	addCommand(project, run, opts)
+
+	for i := range run.Hosts {
+		addHost(project, run, &run.Hosts[i], opts)
+	}
+
+	return project, nil
+}
+
+// addHost converts h and adds it to project along with any issues its
+// scripts reported. With -only-new, hosts and services already in the lair
+// project are left out. It returns false if the host was not imported.
+func addHost(project *lair.Project, run *nmap.NmapRun, h *nmap.Host, opts *options) bool {
+	host, issues, ok := buildHost(run, h, opts)
+	if !ok {
+		if h.Status.State == "up" {
+			opts.summary.skip()
+		}
+		return false
+	}
+	if opts.existing != nil && !opts.existing.onlyNew(host) {
+		verbosef("Skipping host %s, it is already in the project", host.IPv4)
+		opts.summary.skip()
+		return false
+	}
+	project.Hosts = append(project.Hosts, *host)
+	project.Issues = mergeIssues(project.Issues, issues...)
+	return true
+}
+
+// buildHost converts an nmap host from run into a lair host and the issues
+// reported by its scripts. The returned bool is false when the host should
+// not be imported.
+func buildHost(run *nmap.NmapRun, h *nmap.Host, opts *options) (*lair.Host, []lair.Issue, bool) {
+	host := &lair.Host{Tags: append([]string{}, opts.tags...)}
+	h, scanned := opts.translateHost(h)
+	switch {
+	case h.Status.State != "up":
+		debugf("Skipping host %s, it is %s", hostAddress(h), h.Status.State)
+		return nil, nil, false
+	case !opts.includesAddress(hostAddress(h)):
+		return skipHost(h, "outside the included address ranges")
+	case !opts.hasRequiredScript(h):
+		return skipHost(h, "no output from a required script")
+	case opts.isGhost(h):
+		return skipHost(h, "only marked up by -Pn")
+	case !opts.scope.contains(h):
+		opts.scope.skip(h)
+		return nil, nil, false
+	}
+	b := &hostBuilder{opts: opts, host: host}
+	if run != nil {
+		b.scanArgs = run.Args
+	}
+	b.addStatusReason(&h.Status)
+	if scanned != "" {
+		b.addNote(nil, "Scanned address", scanned)
+	}
+
+	for _, address := range h.Addresses {
+		switch {
+		case address.AddrType == "ipv4":
+			host.IPv4 = address.Addr
+		case address.AddrType == "mac":
+			host.MAC = address.Addr
+			vendor := address.Vendor
+			if vendor == "" {
+				vendor = macVendor(address.Addr)
+			}
+			if vendor != "" {
+				b.addNote(nil, "MAC vendor", address.Addr+" "+vendor)
+			}
+		}
+	}
+
+	b.addHostnames(h.Hostnames)
+
+	for _, p := range h.Ports {
+		service := lair.Service{}
+		service.Port = p.PortId
+		service.Protocol = p.Protocol
+
+		if !opts.includesPort(&p) {
+			debugf("Skipping %s port %d/%s on %s, excluded by the state, protocol, or port filters", p.State.State, p.PortId, p.Protocol, hostAddress(h))
+			continue
+		}
+		b.addPortState(&service, &p.State)
+		b.addOwner(&service, &p.Owner)
+
+		product := "Unknown"
+		if p.Service.Product != "" {
+			product = p.Service.Product
+			if p.Service.Version != "" {
+				product += " " + p.Service.Version
+			}
+			if p.Service.ExtraInfo != "" {
+				product += " (" + p.Service.ExtraInfo + ")"
+			}
+		} else if banner := scriptBanner(p.Scripts); banner != "" {
+			product = banner
+		}
+		b.addServiceInfo(&service, &p.Service)
+		if p.Service.ServiceFp != "" {
+			b.addNote(&service, "Service fingerprint", p.Service.ServiceFp)
+		}
+
+		switch {
+		case p.Service.Name == "":
+			if product != "Unknown" {
+				service.Product = product
+			}
+		case !opts.confident(&p.Service):
+			b.addNote(&service, "Service guess", fmt.Sprintf("%s, %s (confidence %d)", opts.mapServiceName(serviceName(&p.Service)), product, p.Service.Configuration))
+		default:
+			service.Service = opts.mapServiceName(serviceName(&p.Service))
+			service.Product = product
+		}
+		if !opts.includesService(service.Service) {
+			debugf("Skipping port %d/%s on %s, excluded by the service filters as %q", p.PortId, p.Protocol, hostAddress(h), service.Service)
+			continue
+		}
+
+		b.addServiceTags(&service)
+		if len(p.Service.CPEs) > 0 {
+			b.addNote(&service, "CPE", strings.Join(cpeStrings(p.Service.CPEs), "\n"))
+		}
+
+		for i := range p.Scripts {
+			b.addScript(&service, &p.Scripts[i])
+		}
+
+		host.Services = append(host.Services, service)
+	}
+
+	for i := range h.HostScripts {
+		b.addScript(nil, &h.HostScripts[i])
+	}
+
+	b.addExtraPorts(h.ExtraPorts)
+
+	if run != nil {
+		host.Tags = appendUnique(host.Tags, scanTags(run.Args)...)
+	}
+	host.Tags = appendUnique(host.Tags, ruleTags(opts.tagRules, hostAddress(h))...)
+
+	if h.Distance.Value > 0 {
+		host.Tags = appendUnique(host.Tags, fmt.Sprintf("hops:%d", h.Distance.Value))
+	}
+
+	b.addScanTimes(run, h)
+	b.addUptime(&h.Uptime)
+	b.addSequences(h)
+	b.addTrace(&h.Trace)
+
+	if os, ok := b.osFromMatches(h.Os.OsMatches); ok {
+		host.OS = os
+	}
+	if b.scriptOS != nil && b.scriptOS.Weight > host.OS.Weight {
+		host.OS = *b.scriptOS
+	}
+
+	switch {
+	case opts.excludesHostnames(host.Hostnames):
+		return skipHost(h, "a hostname is excluded")
+	case len(host.Services) < opts.minPorts:
+		return skipHost(h, fmt.Sprintf("%d services is fewer than -min-ports", len(host.Services)))
+	case opts.limitHosts && len(host.Services) == 0 && !hasHostData(h, host):
+		return skipHost(h, "no services, host script output, or OS")
+	}
+
+	return host, b.issues, true
+}
+
+// mergeHosts combines hosts that share an address into a single host. The
+// services, hostnames, tags, and notes of duplicates are merged, with
+// services from later hosts replacing earlier ones on the same port.
+func mergeHosts(hosts []lair.Host) []lair.Host {
+	var merged []lair.Host
+	index := make(map[string]int)
+	for _, h := range hosts {
+		i, ok := index[h.IPv4]
+		if !ok || h.IPv4 == "" {
+			index[h.IPv4] = len(merged)
+			merged = append(merged, h)
+			continue
+		}
+		mergeHost(&merged[i], &h)
+	}
+	return merged
+}
+
+// mergeHost merges src into dst. Services found on the same port by both,
+// and host notes with the same title, are taken from the host that was
+// scanned last, or from src when the scan times are unknown.
+func mergeHost(dst, src *lair.Host) {
+	newer := !hostLastSeen(src).Before(hostLastSeen(dst))
+	if src.MAC != "" && (newer || dst.MAC == "") {
+		dst.MAC = src.MAC
+	}
+	if src.OS.Weight > dst.OS.Weight {
+		dst.OS = src.OS
+	}
+	dst.Hostnames = appendUnique(dst.Hostnames, src.Hostnames...)
+	dst.Tags = appendUnique(dst.Tags, src.Tags...)
+	dst.Notes = mergeNotes(dst.Notes, src.Notes, newer)
+	for _, service := range src.Services {
+		found := false
+		for i := range dst.Services {
+			if dst.Services[i].Port == service.Port && dst.Services[i].Protocol == service.Protocol {
+				if newer {
+					dst.Services[i] = service
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Services = append(dst.Services, service)
+		}
+	}
+}
+
+// mergeNotes returns the notes of dst and src with one version of each
+// title: the notes src has for a title replace those of dst when newer is
+// set, and are dropped otherwise.
+func mergeNotes(dst, src []lair.Note, newer bool) []lair.Note {
+	titles := make(map[string]bool)
+	for _, note := range dst {
+		titles[note.Title] = true
+	}
+	replace := make(map[string]bool)
+	for _, note := range src {
+		if titles[note.Title] && newer {
+			replace[note.Title] = true
+		}
+	}
+	var merged []lair.Note
+	for _, note := range dst {
+		if !replace[note.Title] {
+			merged = append(merged, note)
+		}
+	}
+	for _, note := range src {
+		if !titles[note.Title] || replace[note.Title] {
+			merged = append(merged, note)
+		}
+	}
+	return merged
+}
+
+// skipHost logs why h is not imported when -verbose is set.
+func skipHost(h *nmap.Host, reason string) (*lair.Host, []lair.Issue, bool) {
+	verbosef("Skipping host %s, %s", hostAddress(h), reason)
+	return nil, nil, false
+}
+
+// hasHostData reports whether h has anything worth importing besides its
+// services: host script output or an OS.
+func hasHostData(h *nmap.Host, host *lair.Host) bool {
+	if host.OS.Fingerprint != "" {
+		return true
+	}
+	for _, script := range h.HostScripts {
+		if strings.TrimSpace(script.Output) != "" || len(script.Elements) > 0 || len(script.Tables) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hostLastSeen returns the time recorded in the "Last seen" note of host, or
+// the zero time if it has none.
+func hostLastSeen(host *lair.Host) time.Time {
+	var last time.Time
+	for _, note := range host.Notes {
+		if note.Title != "Last seen" {
+			continue
+		}
+		line := strings.SplitN(note.Content, "\n", 2)[0]
+		t, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, "Last seen: "))
+		if err == nil && t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// mergeProject adds the commands, hosts, and issues of src to dst, merging
+// hosts that share an address.
+func mergeProject(dst, src *lair.Project) {
+	dst.Commands = append(dst.Commands, src.Commands...)
+	dst.Hosts = mergeHosts(append(dst.Hosts, src.Hosts...))
+	dst.Issues = mergeIssues(dst.Issues, src.Issues...)
+}
+
+// appendUnique appends each value to list unless it is already present.
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces sensitive data removed from script output.
+const redacted = "[REDACTED]"
+
+// readRedactions reads a file of regular expressions, one per line, matching
+// sensitive data to remove from script output. Blank lines and lines
+// starting with # are ignored.
+func readRedactions(path string) ([]*regexp.Regexp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open redactions. Error %s", err.Error())
+	}
+	defer f.Close()
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid redaction on line %d of %s. Error %s", line, path, err.Error())
+		}
+		patterns = append(patterns, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read redactions. Error %s", err.Error())
+	}
+	return patterns, nil
+}
+
+// redact replaces text in s matching a -redact pattern. When a pattern has
+// capture groups only the captured text is replaced, so "password=(\S+)"
+// keeps the "password=" prefix.
+func (o *options) redact(s string) string {
+	for _, re := range o.redactions {
+		if re.NumSubexp() == 0 {
+			s = re.ReplaceAllLiteralString(s, redacted)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			groups := re.FindStringSubmatchIndex(match)
+			var buf strings.Builder
+			last := 0
+			for i := 2; i < len(groups); i += 2 {
+				if groups[i] < last {
+					continue
+				}
+				buf.WriteString(match[last:groups[i]])
+				buf.WriteString(redacted)
+				last = groups[i+1]
+			}
+			buf.WriteString(match[last:])
+			return buf.String()
+		})
+	}
+	return s
+}
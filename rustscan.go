@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+const rustscan = "rustscan"
+
+var (
+	rustscanGreppableRe = regexp.MustCompile(`^(\S+) -> \[([\d,\s]*)\]$`)
+	rustscanOpenRe      = regexp.MustCompile(`^Open (\S+)$`)
+)
+
+// parseRustscan parses rustscan output. Both the greppable format
+// ("10.0.0.1 -> [22,80]") and the "Open 10.0.0.1:22" lines of the default
+// output are understood. Rustscan only reports open TCP ports.
+func parseRustscan(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(rustscan)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := rustscanGreppableRe.FindStringSubmatch(line); m != nil {
+			for _, field := range strings.Split(m[2], ",") {
+				field = strings.TrimSpace(field)
+				if field == "" {
+					continue
+				}
+				id, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid rustscan port %q", field)
+				}
+				set.port(m[1], "tcp", id).State.State = "open"
+			}
+			continue
+		}
+		if m := rustscanOpenRe.FindStringSubmatch(line); m != nil {
+			ip, port, err := net.SplitHostPort(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid rustscan address %q", m[1])
+			}
+			id, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid rustscan port %q", port)
+			}
+			set.port(ip, "tcp", id).State.State = "open"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return set.run, nil
+}
+
+// isRustscan reports whether head looks like rustscan output.
+func isRustscan(head []byte) bool {
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if rustscanGreppableRe.MatchString(line) || rustscanOpenRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the hex encoded SHA256 hash of an empty request body.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// awsCredentials holds the credentials used to sign S3 requests.
+type awsCredentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+}
+
+// fetchS3 downloads the object named by an s3://bucket/key URL. Credentials
+// and the region are read from the standard AWS environment variables or the
+// shared credentials and config files. Setting AWS_ENDPOINT_URL points the
+// request at an S3 compatible server such as MinIO.
+func fetchS3(rawurl string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("Invalid S3 URL %s, expected s3://bucket/key", rawurl)
+	}
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	// The key is used as is rather than joined into a URL and parsed again,
+	// so characters such as ? and % in it stay part of the key.
+	var target *url.URL
+	if endpoint != "" {
+		// Custom endpoints such as MinIO use path style addressing.
+		target, err = url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		target.Path = strings.TrimRight(target.Path, "/") + "/" + bucket + "/" + key
+	} else {
+		target = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, creds.region), Path: "/" + key}
+	}
+	target.RawPath = s3EscapePath(target.Path)
+
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = target
+	signS3Request(req, creds, time.Now().UTC())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unexpected response from %s: %s", rawurl, res.Status)
+	}
+	return res.Body, nil
+}
+
+// signS3Request signs req using AWS Signature Version 4.
+func signS3Request(req *http.Request, creds *awsCredentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptySHA256)
+	if creds.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders string
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + creds.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	key = hmacSHA256(key, creds.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath percent-encodes every byte of path except the unreserved
+// characters of RFC 3986 and /, as Signature Version 4 requires for S3.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// loadAWSCredentials reads credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables,
+// falling back to the profile named by AWS_PROFILE in the shared
+// credentials file.
+func loadAWSCredentials() (*awsCredentials, error) {
+	creds := &awsCredentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       os.Getenv("AWS_REGION"),
+	}
+	if creds.region == "" {
+		creds.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	home, _ := os.UserHomeDir()
+	if creds.accessKey == "" || creds.secretKey == "" {
+		path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+		if path == "" {
+			path = filepath.Join(home, ".aws", "credentials")
+		}
+		values, err := readINISection(path, profile)
+		if err != nil {
+			return nil, err
+		}
+		creds.accessKey = values["aws_access_key_id"]
+		creds.secretKey = values["aws_secret_access_key"]
+		creds.sessionToken = values["aws_session_token"]
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil, errors.New("Missing AWS credentials for S3 input")
+	}
+	if creds.region == "" {
+		path := os.Getenv("AWS_CONFIG_FILE")
+		if path == "" {
+			path = filepath.Join(home, ".aws", "config")
+		}
+		section := "profile " + profile
+		if profile == "default" {
+			section = profile
+		}
+		if values, err := readINISection(path, section); err == nil {
+			creds.region = values["region"]
+		}
+	}
+	if creds.region == "" {
+		creds.region = "us-east-1"
+	}
+	return creds, nil
+}
+
+// readINISection returns the key/value pairs of section in the INI file at
+// path. A missing file yields no values.
+func readINISection(path, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return values, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/lair-framework/api-server/client"
+	"github.com/lair-framework/go-nmap"
+)
+
+// parseScanArgs splits the arguments of the scan command into the lair
+// project id and the arguments to pass to nmap. The project id is optional
+// when LAIR_ID is set and nmap arguments follow a "--" separator.
+func parseScanArgs(args []string, projectID string) (string, []string, error) {
+	for i, arg := range args {
+		if arg != "--" {
+			continue
+		}
+		switch i {
+		case 0:
+		case 1:
+			projectID = args[0]
+		default:
+			return "", nil, errors.New("Too many arguments before --")
+		}
+		if len(args[i+1:]) == 0 {
+			return "", nil, errors.New("Missing nmap arguments")
+		}
+		return projectID, args[i+1:], nil
+	}
+	return "", nil, errors.New("Missing -- before nmap arguments")
+}
+
+// runScan executes nmap with nmapArgs, writing XML to standard output, and
+// imports each host into the lair project as soon as nmap reports it rather
+// than waiting for the whole scan to finish.
+func runScan(c *apiClient, dOpts *client.DOptions, projectID string, nmapArgs []string, opts *options) error {
+	cmd := exec.Command("nmap", append([]string{"-oX", "-"}, nmapArgs...)...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Could not start nmap. Error %s", err.Error())
+	}
+	if opts.showProgress {
+		o := *opts
+		o.progress = newProgress("nmap")
+		opts = &o
+	}
+	imported := 0
+	_, err = parseStream(stdout, opts, func(run *nmap.NmapRun, h *nmap.Host) error {
+		opts.progress.addParsed(1)
+		project := newProject(projectID)
+		outside := opts.scope.count()
+		if !addHost(project, run, h, opts) {
+			return opts.scope.check(outside)
+		}
+		if imported == 0 {
+			addCommand(project, run, opts)
+		}
+		host := project.Hosts[0]
+		if opts.merged != nil {
+			mergeProject(opts.merged, project)
+			imported++
+			log.Printf("Info: Parsed host %s", host.IPv4)
+			return nil
+		}
+		if err := importProject(c, dOpts, project, opts); err != nil {
+			return withExitCode(exitCode(err), fmt.Errorf("Could not import host %s. %s", host.IPv4, err.Error()))
+		}
+		imported++
+		opts.progress.addUploaded(1)
+		log.Printf("Info: Imported host %s", host.IPv4)
+		return nil
+	})
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("nmap failed. Error %s", err.Error())
+	}
+	log.Printf("Info: %d hosts %s", imported, opts.imported())
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+)
+
+// scanTypes maps the letters of nmap's -s options to the scan they select.
+var scanTypes = map[byte]string{
+	'S': "syn",
+	'T': "connect",
+	'U': "udp",
+	'A': "ack",
+	'W': "window",
+	'M': "maimon",
+	'N': "null",
+	'F': "fin",
+	'X': "xmas",
+	'Y': "sctp-init",
+	'Z': "sctp-cookie",
+	'O': "ip-protocol",
+	'V': "version",
+	'C': "scripts",
+	'n': "ping",
+	'L': "list",
+}
+
+// scanTags returns tags describing the scan nmap ran with args, such as
+// scan:syn, scan:udp and scan:top1000, so hosts imported from different scan
+// phases can be told apart.
+func scanTags(args string) []string {
+	program, rest := splitProgram(args)
+	if program != "nmap" {
+		return nil
+	}
+	fields := append([]string{program}, strings.Fields(rest)...)
+	var tags []string
+	add := func(name string) {
+		tags = appendUnique(tags, "scan:"+name)
+	}
+	ports := ""
+	for i := 1; i < len(fields); i++ {
+		f := fields[i]
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		switch {
+		case f == "-A":
+			add("version")
+			add("os")
+			add("scripts")
+			add("traceroute")
+		case f == "-O":
+			add("os")
+		case f == "--traceroute":
+			add("traceroute")
+		case f == "-Pn":
+			add("no-ping")
+		case f == "--script", strings.HasPrefix(f, "--script="):
+			add("scripts")
+		case f == "-F":
+			ports = "top100"
+		case f == "--top-ports":
+			ports = "top" + next
+		case strings.HasPrefix(f, "--top-ports="):
+			ports = "top" + strings.TrimPrefix(f, "--top-ports=")
+		case f == "-p-":
+			ports = "allports"
+		case f == "-p":
+			ports = portsTag(next)
+		case strings.HasPrefix(f, "-p") && len(f) > 2:
+			ports = portsTag(f[2:])
+		case strings.HasPrefix(f, "-s") && len(f) > 2:
+			for j := 2; j < len(f); j++ {
+				if name, ok := scanTypes[f[j]]; ok {
+					add(name)
+				}
+			}
+		}
+	}
+	for _, t := range tags {
+		if t == "scan:ping" || t == "scan:list" {
+			return tags
+		}
+	}
+	if ports == "" {
+		ports = "top1000"
+	}
+	add(ports)
+	return tags
+}
+
+// portsTag returns the tag for a -p port specification.
+func portsTag(spec string) string {
+	if spec == "-" || spec == "1-65535" || spec == "0-65535" {
+		return "allports"
+	}
+	return "custom-ports"
+}
+
+// splitProgram splits the command line args into the name of the program,
+// without its directory or a .exe extension, and the rest of the arguments.
+// The program may be quoted, as nmap records it on Windows when its path
+// contains spaces.
+func splitProgram(args string) (string, string) {
+	args = strings.TrimSpace(args)
+	program, rest := args, ""
+	if strings.HasPrefix(args, `"`) {
+		if i := strings.Index(args[1:], `"`); i >= 0 {
+			program, rest = args[1:i+1], args[i+2:]
+		}
+	} else if i := strings.IndexAny(args, " \t"); i >= 0 {
+		program, rest = args[:i], args[i:]
+	}
+	if i := strings.LastIndexAny(program, `/\`); i >= 0 {
+		program = program[i+1:]
+	}
+	program = strings.ToLower(program)
+	return strings.TrimSuffix(program, ".exe"), rest
+}
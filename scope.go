@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+// scope is the allowlist of addresses and hostnames given with -scope.
+// Hosts outside it are never imported, and with abort set the input they
+// appear in is not imported at all.
+type scope struct {
+	nets      []*net.IPNet
+	hostnames []string
+	abort     bool
+	outside   int
+}
+
+// readScope reads a scope file listing one address, CIDR range, or hostname
+// per line. Hostnames may use wildcards such as *.example.com. Blank lines
+// and lines starting with # are ignored.
+func readScope(filename string, abort bool) (*scope, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open scope. Error %s", err.Error())
+	}
+	defer f.Close()
+	s := &scope{abort: abort}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if n, err := parseCIDR(text); err == nil {
+			s.nets = append(s.nets, n)
+			continue
+		}
+		s.hostnames = append(s.hostnames, strings.ToLower(text))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read scope. Error %s", err.Error())
+	}
+	if len(s.nets) == 0 && len(s.hostnames) == 0 {
+		return nil, fmt.Errorf("Scope %s is empty", filename)
+	}
+	return s, nil
+}
+
+// contains reports whether the address or one of the hostnames of h is in
+// scope. A nil scope contains every host.
+func (s *scope) contains(h *nmap.Host) bool {
+	if s == nil {
+		return true
+	}
+	if ip := net.ParseIP(hostAddress(h)); ip != nil && containsIP(s.nets, ip) {
+		return true
+	}
+	for _, hostname := range h.Hostnames {
+		name := strings.ToLower(strings.TrimSuffix(hostname.Name, "."))
+		for _, pattern := range s.hostnames {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skip logs and counts a host that is out of scope.
+func (s *scope) skip(h *nmap.Host) {
+	s.outside++
+	log.Printf("Warning: Skipping out of scope host %s", hostAddress(h))
+}
+
+// check returns an error when hosts were found out of scope since the count
+// was at before and the scope aborts on them.
+func (s *scope) check(before int) error {
+	if s == nil || !s.abort || s.outside == before {
+		return nil
+	}
+	return fmt.Errorf("%d hosts are out of scope", s.outside-before)
+}
+
+// count returns the number of out of scope hosts found so far.
+func (s *scope) count() int {
+	if s == nil {
+		return 0
+	}
+	return s.outside
+}
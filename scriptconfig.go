@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+	"gopkg.in/yaml.v2"
+)
+
+// Actions a script mapping rule can take.
+const (
+	actionNote   = "note"
+	actionIssue  = "issue"
+	actionTag    = "tag"
+	actionIgnore = "ignore"
+)
+
+// scriptConfig holds the rules read from a script mapping file, keyed by
+// script id or a pattern such as http-vuln-*.
+type scriptConfig struct {
+	Scripts map[string]*scriptRule `yaml:"scripts"`
+
+	patterns []string
+}
+
+// scriptRule defines how the output of a script is imported. Rules with a
+// match expression only apply when the output of the script matches it.
+type scriptRule struct {
+	Action      string  `yaml:"action"`
+	Match       string  `yaml:"match"`
+	Tag         string  `yaml:"tag"`
+	Title       string  `yaml:"title"`
+	CVSS        float64 `yaml:"cvss"`
+	Description string  `yaml:"description"`
+	Solution    string  `yaml:"solution"`
+
+	match *regexp.Regexp
+}
+
+// readScriptConfig reads a script mapping file in YAML or JSON, such as:
+//
+//	scripts:
+//	  ssh2-enum-algos:
+//	    action: ignore
+//	  ftp-anon:
+//	    action: issue
+//	    match: Anonymous FTP login allowed
+//	    title: Anonymous FTP access
+//	    cvss: 5.0
+//	  vnc-info:
+//	    action: tag
+//	    tag: vnc
+func readScriptConfig(filename string) (*scriptConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read script config. Error %s", err.Error())
+	}
+	config := &scriptConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Could not parse script config. Error %s", err.Error())
+	}
+	for id, rule := range config.Scripts {
+		if rule == nil {
+			return nil, fmt.Errorf("Script config for %s has no action", id)
+		}
+		switch rule.Action {
+		case actionNote, actionIgnore, actionTag:
+		case actionIssue:
+			if rule.Title == "" {
+				return nil, fmt.Errorf("Script config for %s needs a title for its issue", id)
+			}
+		default:
+			return nil, fmt.Errorf("Script config for %s has invalid action %q, expected note, issue, tag or ignore", id, rule.Action)
+		}
+		if rule.Match != "" {
+			if rule.match, err = regexp.Compile(rule.Match); err != nil {
+				return nil, fmt.Errorf("Script config for %s has an invalid match. Error %s", id, err.Error())
+			}
+		}
+		if _, err := path.Match(id, ""); err != nil {
+			return nil, fmt.Errorf("Script config for %s has an invalid pattern. Error %s", id, err.Error())
+		}
+		config.patterns = append(config.patterns, id)
+	}
+	sort.Strings(config.patterns)
+	return config, nil
+}
+
+// rule returns the rule for script, preferring a rule for its exact id over
+// a pattern. It returns nil when no rule applies.
+func (c *scriptConfig) rule(script *nmap.Script) *scriptRule {
+	if c == nil {
+		return nil
+	}
+	if rule, ok := c.Scripts[script.Id]; ok && rule.matches(script) {
+		return rule
+	}
+	for _, pattern := range c.patterns {
+		if ok, _ := path.Match(pattern, script.Id); ok && c.Scripts[pattern].matches(script) {
+			return c.Scripts[pattern]
+		}
+	}
+	return nil
+}
+
+func (r *scriptRule) matches(script *nmap.Script) bool {
+	return r.match == nil || r.match.MatchString(script.Output)
+}
+
+// applyRule imports script as rule defines.
+func (b *hostBuilder) applyRule(rule *scriptRule, service *lair.Service, script *nmap.Script) {
+	switch rule.Action {
+	case actionNote:
+		b.addScriptNote(service, script.Id, scriptContent(script))
+	case actionTag:
+		tag := rule.Tag
+		if tag == "" {
+			tag = "script:" + script.Id
+		}
+		b.host.Tags = appendUnique(b.host.Tags, tag)
+	case actionIssue:
+		issue := newIssue(script, rule.Title)
+		issue.Description = rule.Description
+		issue.Solution = rule.Solution
+		issue.IsConfirmed = true
+		setCVSS(&issue, rule.CVSS, "")
+		b.addIssue(service, issue)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// hostBuilder holds the lair data produced while converting a single nmap
+// host.
+type hostBuilder struct {
+	opts     *options
+	host     *lair.Host
+	issues   []lair.Issue
+	scriptOS *lair.OS
+
+	// scanArgs is the nmap command line the host was scanned with.
+	scanArgs string
+
+	// scriptNotes maps the hash of each script note added to a service to
+	// the port it was added to.
+	scriptNotes map[string]string
+}
+
+// scriptMapper converts the output of a specific NSE script into lair data.
+// It returns false to fall back to the default handling of the script.
+type scriptMapper func(b *hostBuilder, service *lair.Service, script *nmap.Script) bool
+
+// scriptMappers holds the mappers for scripts that need special handling,
+// keyed by script id.
+var scriptMappers = map[string]scriptMapper{
+	"dns-brute":          mapDNSBrute,
+	"hostmap-bfk":        mapHostmap,
+	"hostmap-crtsh":      mapHostmap,
+	"hostmap-ip2hosts":   mapHostmap,
+	"http-enum":          mapHTTPEnum,
+	"http-methods":       mapHTTPMethods,
+	"http-ntlm-info":     mapNTLMInfo,
+	"http-title":         mapHTTPTitle,
+	"imap-ntlm-info":     mapNTLMInfo,
+	"ms-sql-info":        mapMSSQLInfo,
+	"ms-sql-ntlm-info":   mapNTLMInfo,
+	"pop3-ntlm-info":     mapNTLMInfo,
+	"rdp-ntlm-info":      mapNTLMInfo,
+	"smb-enum-shares":    mapSMBEnumShares,
+	"smb-os-discovery":   mapSMBOSDiscovery,
+	"smb-protocols":      mapSMBProtocols,
+	"smb-security-mode":  mapSMBSecurityMode,
+	"smb2-security-mode": mapSMBSecurityMode,
+	"smtp-ntlm-info":     mapNTLMInfo,
+	"snmp-brute":         mapSNMPBrute,
+	"snmp-info":          mapSNMPHostNote,
+	"snmp-interfaces":    mapSNMPHostNote,
+	"snmp-sysdescr":      mapSNMPHostNote,
+	"ssh-hostkey":        mapSSHHostKey,
+	"ssl-cert":           mapSSLCert,
+	"ssl-enum-ciphers":   mapSSLEnumCiphers,
+	"telnet-ntlm-info":   mapNTLMInfo,
+	"vulners":            mapVulners,
+	"vulscan":            mapVulscan,
+}
+
+// addScript converts the output of an NSE script run against service, or
+// against the host when service is nil. Rules from a script mapping file are
+// applied first. Otherwise scripts that report vulnerabilities become
+// issues, everything else becomes a note.
+func (b *hostBuilder) addScript(service *lair.Service, script *nmap.Script) {
+	if rule := b.opts.scriptConfig.rule(script); rule != nil {
+		b.applyRule(rule, service, script)
+		return
+	}
+	if mapper, ok := scriptMappers[script.Id]; ok && mapper(b, service, script) {
+		return
+	}
+	if b.addVulnIssues(service, script) {
+		return
+	}
+	b.addScriptNote(service, script.Id, scriptContent(script))
+}
+
+// scriptContent returns the note content for script. Structured output is
+// rendered as an indented outline after the text output.
+func scriptContent(script *nmap.Script) string {
+	var buf bytes.Buffer
+	renderElements(&buf, script.Elements, "")
+	renderTables(&buf, script.Tables, "")
+	if buf.Len() == 0 {
+		return script.Output
+	}
+	output := strings.Trim(script.Output, "\n")
+	if output == "" {
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return output + "\n\n" + strings.TrimSuffix(buf.String(), "\n")
+}
+
+// renderElements writes elements as "key: value" lines, or list items for
+// elements without a key, at indent.
+func renderElements(buf *bytes.Buffer, elements []nmap.Element, indent string) {
+	for _, e := range elements {
+		value := strings.Replace(strings.TrimSpace(e.Value), "\n", "\n"+indent+"  ", -1)
+		if e.Key == "" {
+			fmt.Fprintf(buf, "%s- %s\n", indent, value)
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, e.Key, value)
+	}
+}
+
+// renderTables writes tables and their contents as a nested outline.
+func renderTables(buf *bytes.Buffer, tables []nmap.Table, indent string) {
+	for i := range tables {
+		t := &tables[i]
+		if t.Key == "" {
+			fmt.Fprintf(buf, "%s-\n", indent)
+		} else {
+			fmt.Fprintf(buf, "%s%s:\n", indent, t.Key)
+		}
+		renderElements(buf, t.Elements, indent+"  ")
+		renderTables(buf, t.Table, indent+"  ")
+	}
+}
+
+// addScriptNote adds a note with script output to service, or to the host
+// when service is nil. Output identical to that of the same script on
+// another port of the host, such as the same certificate on several ports,
+// is replaced by a reference to the first port. Output is redacted with the
+// -redact patterns, then output larger than -skip-note-size is dropped and
+// output larger than -max-note-size is truncated. Every note holding script
+// output, including those added by script mappers, must be added with it so
+// that -redact applies.
+func (b *hostBuilder) addScriptNote(service *lair.Service, title, content string) {
+	content = b.opts.redact(content)
+	if b.opts.skipNoteSize > 0 && int64(len(content)) > b.opts.skipNoteSize {
+		log.Printf("Warning: Skipping %d bytes of %s output on host %s", len(content), title, b.host.IPv4)
+		return
+	}
+	content = truncateNote(content, b.opts.maxNoteSize)
+	if service == nil {
+		b.addNote(nil, title, content)
+		return
+	}
+	sum := sha1.Sum([]byte(title + "\x00" + content))
+	key := hex.EncodeToString(sum[:])
+	if port, ok := b.scriptNotes[key]; ok {
+		b.addNote(service, title, "Same output as "+port)
+		return
+	}
+	if b.scriptNotes == nil {
+		b.scriptNotes = map[string]string{}
+	}
+	b.scriptNotes[key] = fmt.Sprintf("%d/%s", service.Port, service.Protocol)
+	b.addNote(service, title, content)
+}
+
+// addNote adds a note to service, or to the host when service is nil.
+func (b *hostBuilder) addNote(service *lair.Service, title, content string) {
+	note := lair.Note{Title: title, Content: content, LastModifiedBy: tool}
+	if service == nil {
+		b.host.Notes = append(b.host.Notes, note)
+		return
+	}
+	service.Notes = append(service.Notes, note)
+}
+
+// truncateNote shortens content to at most max bytes, marking how much was
+// removed. A max of zero or less disables the limit.
+func truncateNote(content string, max int64) string {
+	if max <= 0 || int64(len(content)) <= max {
+		return content
+	}
+	cut := int(max)
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n[truncated %d bytes]", content[:cut], len(content)-cut)
+}
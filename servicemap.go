@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readServiceMap reads a service name mapping file. Each line holds the name
+// of a service as it would be imported, an arrow, and the name to import it
+// as instead, such as "microsoft-ds -> smb". Blank lines and lines starting
+// with # are ignored.
+func readServiceMap(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open service map. Error %s", err.Error())
+	}
+	defer f.Close()
+	names := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "->", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("Invalid service mapping on line %d of %s, expected '<name> -> <name>'", line, path)
+		}
+		names[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read service map. Error %s", err.Error())
+	}
+	return names, nil
+}
+
+// mapServiceName returns the name name is mapped to by -service-map, or name
+// itself when it is not mapped.
+func (o *options) mapServiceName(name string) string {
+	if mapped, ok := o.serviceMap[name]; ok {
+		return mapped
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// mapSMBEnumShares converts smb-enum-shares into a note with one line per
+// share giving its type, the anonymous and current user access, and comment.
+func mapSMBEnumShares(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var lines []string
+	if account := scriptElem(script, "account_used"); account != "" {
+		lines = append(lines, "Account used: "+account)
+	}
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		if !strings.HasPrefix(t.Key, `\\`) {
+			continue
+		}
+		lines = append(lines, shareLine(t.Key, tableElem(t, "Type"), tableElem(t, "Anonymous access"), tableElem(t, "Current user access"), tableElem(t, "Comment")))
+	}
+	if len(script.Tables) == 0 {
+		share := ""
+		fields := map[string]string{}
+		flush := func() {
+			if share != "" {
+				lines = append(lines, shareLine(share, fields["Type"], fields["Anonymous access"], fields["Current user access"], fields["Comment"]))
+			}
+		}
+		for _, line := range strings.Split(script.Output, "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, `\\`):
+				flush()
+				share = strings.TrimSuffix(line, ":")
+				fields = map[string]string{}
+			case strings.HasPrefix(line, "account_used:"):
+				lines = append(lines, "Account used: "+strings.TrimSpace(strings.TrimPrefix(line, "account_used:")))
+			default:
+				if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+					fields[parts[0]] = strings.TrimSpace(parts[1])
+				}
+			}
+		}
+		flush()
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	b.addScriptNote(service, script.Id, strings.Join(lines, "\n"))
+	return true
+}
+
+func shareLine(share, kind, anonymous, user, comment string) string {
+	line := fmt.Sprintf("%s (%s) anonymous: %s, current user: %s", share, kind, anonymous, user)
+	if comment != "" {
+		line += " - " + comment
+	}
+	return line
+}
+
+// mapSMBSecurityMode converts smb-security-mode and smb2-security-mode into a
+// note with the signing configuration, and raises an issue when the server
+// does not require message signing.
+func mapSMBSecurityMode(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var lines []string
+	for _, key := range []string{"account_used", "authentication_level", "challenge_response", "message_signing"} {
+		value := scriptElem(script, key)
+		if value == "" {
+			value = outputField(script.Output, key)
+		}
+		if value != "" {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	for i := range script.Tables {
+		for _, e := range script.Tables[i].Elements {
+			lines = append(lines, script.Tables[i].Key+": "+strings.TrimSpace(e.Value))
+		}
+	}
+	if len(script.Tables) == 0 {
+		for _, line := range strings.Split(script.Output, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.Contains(line, "signing") && !strings.HasPrefix(line, "message_signing:") {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	content := strings.Join(lines, "\n")
+	b.addScriptNote(service, script.Id, content)
+
+	lower := strings.ToLower(content)
+	if strings.Contains(lower, "not required") || strings.Contains(lower, "message_signing: disabled") || strings.Contains(lower, "message_signing: supported") {
+		issue := newIssue(script, "SMB signing not required")
+		issue.Description = "The SMB server does not require message signing, which allows an attacker to relay or tamper with SMB sessions."
+		issue.Solution = "Require SMB message signing on the server."
+		issue.Evidence = content
+		issue.IsConfirmed = true
+		b.addIssue(service, issue)
+	}
+	return true
+}
+
+// mapSMBProtocols raises an issue when smb-protocols reports that the server
+// supports SMBv1. The output is still imported as a note.
+func mapSMBProtocols(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	if !strings.Contains(script.Output, "SMBv1") && !strings.Contains(script.Output, "NT LM 0.12") {
+		return false
+	}
+	issue := newIssue(script, "SMBv1 enabled")
+	issue.Description = "The SMB server supports version 1 of the protocol, which is deprecated and affected by several critical vulnerabilities such as MS17-010."
+	issue.Solution = "Disable SMBv1 on the server."
+	issue.IsConfirmed = true
+	b.addIssue(service, issue)
+	return false
+}
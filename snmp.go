@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// snmpCommunityRe matches a community string snmp-brute found, such as
+// "public - Valid credentials".
+var snmpCommunityRe = regexp.MustCompile(`(?m)^\s*(\S+) - Valid credentials`)
+
+// snmpCommunityArgRe matches the snmpcommunity script argument in the nmap
+// command line, which sets the community the SNMP scripts use.
+var snmpCommunityArgRe = regexp.MustCompile(`snmpcommunity\s*=\s*["']?([^,"'\s}]+)`)
+
+// snmpDefaultCommunities lists the community strings that are raised as an
+// issue when snmp-brute finds them.
+var snmpDefaultCommunities = []string{"public", "private"}
+
+// mapSNMPHostNote imports the system information gathered by the snmp-info,
+// snmp-sysdescr and snmp-interfaces scripts as a host note, since it
+// describes the host rather than the SNMP service. These scripts use the
+// public community unless the snmpcommunity script argument sets another, so
+// their output shows that public is accepted.
+func mapSNMPHostNote(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	content := scriptContent(script)
+	if strings.TrimSpace(content) == "" {
+		return false
+	}
+	b.addScriptNote(nil, script.Id, content)
+	if m := snmpCommunityArgRe.FindStringSubmatch(b.scanArgs); m == nil || strings.EqualFold(m[1], "public") {
+		b.addSNMPCommunityIssue(service, script, []string{"public"})
+	}
+	return true
+}
+
+// mapSNMPBrute raises an issue when snmp-brute finds that a default
+// community string such as public is accepted. The output is still imported
+// as a note.
+func mapSNMPBrute(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var found []string
+	for _, m := range snmpCommunityRe.FindAllStringSubmatch(script.Output, -1) {
+		for _, community := range snmpDefaultCommunities {
+			if strings.EqualFold(m[1], community) {
+				found = appendUnique(found, community)
+			}
+		}
+	}
+	if len(found) == 0 {
+		return false
+	}
+	b.addSNMPCommunityIssue(service, script, found)
+	return false
+}
+
+// addSNMPCommunityIssue raises an issue for the default community strings
+// script found to be accepted.
+func (b *hostBuilder) addSNMPCommunityIssue(service *lair.Service, script *nmap.Script, found []string) {
+	issue := newIssue(script, "SNMP agent accepts default community string")
+	issue.Description = "The SNMP agent accepts the default community string " + strings.Join(found, ", ") + ", allowing anyone to read system information and possibly change the configuration of the host."
+	issue.Solution = "Disable SNMP if it is not required, or configure a strong community string and restrict access to management hosts."
+	issue.IsConfirmed = true
+	setCVSS(&issue, cvssHigh, "")
+	b.addIssue(service, issue)
+}
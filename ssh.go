@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// fetchSSH streams the file named by an ssh://[user@]host[:port]/path URL by
+// running "cat" on the remote host with the system ssh client, so the
+// user's ssh config and agent are used for authentication. A path starting
+// with /~/ is relative to the remote home directory.
+func fetchSSH(rawurl string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("Invalid SSH URL %s, expected ssh://[user@]host/path", rawurl)
+	}
+	// A host or user starting with - would be read by ssh as an option,
+	// such as -oProxyCommand, which runs a local command.
+	if strings.HasPrefix(u.Hostname(), "-") || (u.User != nil && strings.HasPrefix(u.User.Username(), "-")) {
+		return nil, fmt.Errorf("Invalid SSH URL %s, the host and user may not start with -", rawurl)
+	}
+	args := []string{"-o", "BatchMode=yes"}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	target := u.Hostname()
+	if u.User != nil && u.User.Username() != "" {
+		target = u.User.Username() + "@" + target
+	}
+	remote := shellQuote(u.Path)
+	if strings.HasPrefix(u.Path, "/~/") {
+		remote = "~/" + shellQuote(strings.TrimPrefix(u.Path, "/~/"))
+	}
+	args = append(args, "--", target, "cat -- "+remote)
+
+	cmd := exec.Command("ssh", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Could not start ssh. Error %s", err.Error())
+	}
+	return &sshReader{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+// sshReader reads the output of a remote command, reporting a failure of
+// the command as an error once its output has been consumed.
+type sshReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	done   bool
+}
+
+func (r *sshReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		if werr := r.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("ssh failed: %s", strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+func (r *sshReader) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	r.stdout.Close()
+	r.cmd.Process.Kill()
+	r.cmd.Wait()
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell command.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// sshHostKeyRe matches a key in the text output of ssh-hostkey, such as
+// "2048 aa:bb:cc (RSA)".
+var sshHostKeyRe = regexp.MustCompile(`^(\d+) ([0-9a-fA-F:]+) \((\S+)\)$`)
+
+// mapSSHHostKey converts the ssh-hostkey script into a note with one line per
+// key giving its type, size and fingerprint, so reused keys can be searched
+// for across hosts.
+func mapSSHHostKey(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var lines []string
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		fingerprint := tableElem(t, "fingerprint")
+		if fingerprint == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Type: %s, Bits: %s, Fingerprint: %s", tableElem(t, "type"), tableElem(t, "bits"), colonHex(fingerprint)))
+	}
+	if len(lines) == 0 {
+		for _, line := range strings.Split(script.Output, "\n") {
+			if m := sshHostKeyRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				lines = append(lines, fmt.Sprintf("Type: %s, Bits: %s, Fingerprint: %s", m[3], m[1], colonHex(m[2])))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return false
+	}
+	b.addScriptNote(service, script.Id, strings.Join(lines, "\n"))
+	return true
+}
+
+// colonHex formats a hex fingerprint with a colon between each byte.
+func colonHex(s string) string {
+	s = strings.ToLower(strings.Replace(s, ":", "", -1))
+	var parts []string
+	for i := 0; i+2 <= len(s); i += 2 {
+		parts = append(parts, s[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+// certificate holds the fields of an ssl-cert result that are imported.
+type certificate struct {
+	subject    string
+	commonName string
+	issuer     string
+	altNames   []string
+	notBefore  string
+	notAfter   string
+	sigAlgo    string
+}
+
+// mapSSLCert converts the ssl-cert script into a note listing the subject,
+// issuer, alternative names and validity of the certificate, and adds the
+// names the certificate is valid for to the hostnames of the host.
+func mapSSLCert(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	cert := certFromTables(script)
+	if cert == nil {
+		cert = certFromOutput(script.Output)
+	}
+	if cert == nil {
+		return false
+	}
+
+	var content []string
+	addLine := func(name, value string) {
+		if value != "" {
+			content = append(content, name+": "+value)
+		}
+	}
+	addLine("Subject", cert.subject)
+	addLine("Issuer", cert.issuer)
+	addLine("Subject Alternative Names", strings.Join(cert.altNames, ", "))
+	addLine("Not valid before", cert.notBefore)
+	addLine("Not valid after", cert.notAfter)
+	addLine("Signature algorithm", cert.sigAlgo)
+	b.addScriptNote(service, script.Id, strings.Join(content, "\n"))
+
+	names := append([]string{cert.commonName}, cert.altNames...)
+	for _, name := range names {
+		if isCertHostname(name) {
+			b.host.Hostnames = appendUnique(b.host.Hostnames, strings.ToLower(name))
+		}
+	}
+	return true
+}
+
+// certFromTables reads a certificate from the structured output of ssl-cert.
+func certFromTables(script *nmap.Script) *certificate {
+	cert := &certificate{}
+	found := false
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		switch t.Key {
+		case "subject":
+			cert.subject = joinNameTable(t)
+			cert.commonName = tableElem(t, "commonName")
+			found = true
+		case "issuer":
+			cert.issuer = joinNameTable(t)
+		case "validity":
+			cert.notBefore = tableElem(t, "notBefore")
+			cert.notAfter = tableElem(t, "notAfter")
+		case "extensions":
+			for j := range t.Table {
+				if tableElem(&t.Table[j], "name") == "X509v3 Subject Alternative Name" {
+					cert.altNames = parseAltNames(tableElem(&t.Table[j], "value"))
+				}
+			}
+		}
+	}
+	for _, e := range script.Elements {
+		if e.Key == "sig_algo" {
+			cert.sigAlgo = strings.TrimSpace(e.Value)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return cert
+}
+
+// certFromOutput reads a certificate from the text output of ssl-cert.
+func certFromOutput(output string) *certificate {
+	cert := &certificate{}
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch parts[0] {
+		case "Subject":
+			cert.subject = value
+			for _, field := range strings.Split(value, "/") {
+				if strings.HasPrefix(field, "commonName=") {
+					cert.commonName = strings.TrimPrefix(field, "commonName=")
+				}
+			}
+			found = true
+		case "Issuer":
+			cert.issuer = value
+		case "Subject Alternative Name":
+			cert.altNames = parseAltNames(value)
+		case "Not valid before":
+			cert.notBefore = value
+		case "Not valid after":
+			cert.notAfter = value
+		case "Signature Algorithm":
+			cert.sigAlgo = value
+		}
+	}
+	if !found {
+		return nil
+	}
+	return cert
+}
+
+// joinNameTable formats a subject or issuer table as name=value pairs.
+func joinNameTable(t *nmap.Table) string {
+	var fields []string
+	for _, e := range t.Elements {
+		fields = append(fields, e.Key+"="+strings.TrimSpace(e.Value))
+	}
+	return strings.Join(fields, "/")
+}
+
+// parseAltNames returns the DNS names in a subject alternative name
+// extension such as "DNS:a.example.com, IP Address:10.0.0.1".
+func parseAltNames(value string) []string {
+	var names []string
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), ":", 2)
+		if len(parts) == 2 && parts[0] == "DNS" {
+			names = appendUnique(names, strings.TrimSpace(parts[1]))
+		}
+	}
+	return names
+}
+
+// isCertHostname reports whether name from a certificate can be used as a
+// hostname. Addresses, wildcard names and names without a domain are
+// skipped.
+func isCertHostname(name string) bool {
+	return net.ParseIP(name) == nil && !strings.Contains(name, "*") && strings.Contains(name, ".") && !strings.ContainsAny(name, " /")
+}
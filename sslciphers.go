@@ -0,0 +1,134 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+var (
+	sslProtocolRe = regexp.MustCompile(`^(SSLv\d|TLSv\d\.\d):$`)
+	sslCipherRe   = regexp.MustCompile(`^(\S+) \(.*\) - ([A-F])$`)
+)
+
+// weakSSLProtocols are the protocol versions that are raised as an issue
+// when offered.
+var weakSSLProtocols = map[string]bool{"SSLv2": true, "SSLv3": true, "TLSv1.0": true}
+
+// weakCipherGrades are the ssl-enum-ciphers grades of C or worse. Other
+// strengths, such as unknown, are not raised.
+var weakCipherGrades = map[string]bool{"C": true, "D": true, "E": true, "F": true}
+
+// sslCipher is a cipher suite offered for a protocol version with the grade
+// ssl-enum-ciphers gave it.
+type sslCipher struct {
+	protocol string
+	name     string
+	grade    string
+}
+
+// mapSSLEnumCiphers raises an issue when ssl-enum-ciphers finds a deprecated
+// protocol version, such as SSLv3 or TLSv1.0, or ciphers graded C or worse.
+// The issue lists the weak protocols, ciphers and warnings. Configurations
+// without weaknesses are imported as a note.
+func mapSSLEnumCiphers(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	protocols, ciphers, warnings := sslCiphersFromTables(script)
+	if len(protocols) == 0 {
+		protocols, ciphers, warnings = sslCiphersFromOutput(script.Output)
+	}
+
+	var weakProtocols, weakCiphers []string
+	for _, p := range protocols {
+		if weakSSLProtocols[p] {
+			weakProtocols = append(weakProtocols, p)
+		}
+	}
+	for _, c := range ciphers {
+		if weakCipherGrades[c.grade] {
+			weakCiphers = append(weakCiphers, c.protocol+" "+c.name+" - "+c.grade)
+		}
+	}
+	if len(weakProtocols) == 0 && len(weakCiphers) == 0 {
+		return false
+	}
+
+	var description []string
+	if len(weakProtocols) > 0 {
+		description = append(description, "Deprecated protocols offered: "+strings.Join(weakProtocols, ", "))
+	}
+	if len(weakCiphers) > 0 {
+		description = append(description, "Ciphers graded C or worse:\n"+strings.Join(weakCiphers, "\n"))
+	}
+	if len(warnings) > 0 {
+		description = append(description, "Warnings:\n"+strings.Join(warnings, "\n"))
+	}
+	issue := newIssue(script, "Weak SSL/TLS configuration")
+	issue.Description = strings.Join(description, "\n\n")
+	issue.Solution = "Disable SSLv3 and TLSv1.0 and remove cipher suites graded C or worse from the server configuration."
+	issue.IsConfirmed = true
+	b.addIssue(service, issue)
+	return true
+}
+
+// sslCiphersFromTables reads the structured output of ssl-enum-ciphers, which
+// has a table for each protocol version.
+func sslCiphersFromTables(script *nmap.Script) ([]string, []sslCipher, []string) {
+	var protocols, warnings []string
+	var ciphers []sslCipher
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		if !strings.HasPrefix(t.Key, "SSLv") && !strings.HasPrefix(t.Key, "TLSv") {
+			continue
+		}
+		protocols = append(protocols, t.Key)
+		for j := range t.Table {
+			switch t.Table[j].Key {
+			case "ciphers":
+				for k := range t.Table[j].Table {
+					c := &t.Table[j].Table[k]
+					ciphers = append(ciphers, sslCipher{protocol: t.Key, name: tableElem(c, "name"), grade: tableElem(c, "strength")})
+				}
+			case "warnings":
+				for _, e := range t.Table[j].Elements {
+					warnings = appendUnique(warnings, strings.TrimSpace(e.Value))
+				}
+			}
+		}
+	}
+	return protocols, ciphers, warnings
+}
+
+// sslCiphersFromOutput reads the text output of ssl-enum-ciphers.
+func sslCiphersFromOutput(output string) ([]string, []sslCipher, []string) {
+	var protocols, warnings []string
+	var ciphers []sslCipher
+	protocol, section := "", ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "|_"))
+		if m := sslProtocolRe.FindStringSubmatch(line); m != nil {
+			protocol, section = m[1], ""
+			protocols = append(protocols, protocol)
+			continue
+		}
+		if strings.HasSuffix(line, ":") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		if protocol == "" {
+			continue
+		}
+		switch section {
+		case "ciphers":
+			if m := sslCipherRe.FindStringSubmatch(line); m != nil {
+				ciphers = append(ciphers, sslCipher{protocol: protocol, name: m[1], grade: m[2]})
+			}
+		case "warnings":
+			if line != "" && !strings.Contains(line, ": ") {
+				warnings = appendUnique(warnings, line)
+			}
+		}
+	}
+	return protocols, ciphers, warnings
+}
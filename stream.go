@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+// parseStream decodes nmap XML from r one element at a time, calling fn for
+// each host as soon as it has been decoded. Hosts are not retained, so
+// memory use stays bounded regardless of the scan size. The returned runs
+// contain the scan metadata without any hosts.
+//
+// Files written with --append-output contain several concatenated nmaprun
+// documents; a run is returned for each of them and fn is passed the run
+// that each host belongs to.
+//
+// When opts.recover is true, a document that ends before it is complete,
+// as happens when nmap is killed mid-scan, is not an error. Every complete
+// host is still passed to fn and a warning is logged.
+//
+// Element nesting is limited to opts.maxDepth and documents declaring
+// entities are rejected so hostile input cannot exhaust memory.
+func parseStream(r io.Reader, opts *options, fn func(run *nmap.NmapRun, h *nmap.Host) error) ([]*nmap.NmapRun, error) {
+	raw := xml.NewDecoder(r)
+	raw.CharsetReader = charsetReader
+	d := xml.NewTokenDecoder(&limitTokenReader{d: raw, maxDepth: opts.maxDepth})
+	recoverTruncated := opts.recover
+	var runs []*nmap.NmapRun
+	var run *nmap.NmapRun
+	hosts := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return runs, truncatedError(run, err, recoverTruncated, hosts)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if se.Name.Local == "nmaprun" {
+			run = &nmap.NmapRun{}
+			if err := decodeRunAttrs(run, se.Attr); err != nil {
+				return nil, err
+			}
+			runs = append(runs, run)
+			continue
+		}
+		if run == nil {
+			return nil, errors.New("Root element is not nmaprun")
+		}
+		switch se.Name.Local {
+		case "host":
+			h := &nmap.Host{}
+			if err := d.DecodeElement(h, &se); err != nil {
+				return runs, truncatedError(run, err, recoverTruncated, hosts)
+			}
+			if err := fn(run, h); err != nil {
+				return runs, err
+			}
+			hosts++
+		case "scaninfo":
+			if err := d.DecodeElement(&run.ScanInfo, &se); err != nil {
+				return runs, err
+			}
+		case "runstats":
+			if err := d.DecodeElement(&run.RunStats, &se); err != nil {
+				return runs, err
+			}
+		}
+	}
+	if len(runs) == 0 {
+		return nil, errors.New("No nmaprun element found")
+	}
+	return runs, nil
+}
+
+// truncatedError inspects a decoding error. If it was caused by the input
+// ending early and recoverTruncated is true, a warning is logged and nil is
+// returned so the hosts decoded so far are kept.
+func truncatedError(run *nmap.NmapRun, err error, recoverTruncated bool, hosts int) error {
+	if run == nil || !isTruncated(err) {
+		return err
+	}
+	if !recoverTruncated {
+		return fmt.Errorf("%s. The input appears to be truncated, use -recover to import the complete hosts", err.Error())
+	}
+	log.Printf("Warning: Input is truncated, recovered %d complete hosts", hosts)
+	return nil
+}
+
+// isTruncated reports whether err indicates that the XML input ended
+// before the document was complete.
+func isTruncated(err error) bool {
+	if err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if serr, ok := err.(*xml.SyntaxError); ok {
+		return serr.Msg == "unexpected EOF"
+	}
+	return false
+}
+
+// decodeRunAttrs populates the scan metadata of run from the attributes of
+// the nmaprun element.
+func decodeRunAttrs(run *nmap.NmapRun, attrs []xml.Attr) error {
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "scanner":
+			run.Scanner = attr.Value
+		case "args":
+			run.Args = attr.Value
+		case "start":
+			if err := run.Start.UnmarshalXMLAttr(attr); err != nil {
+				return err
+			}
+		case "startstr":
+			run.StartStr = attr.Value
+		case "version":
+			run.Version = attr.Value
+		case "profile_name":
+			run.ProfileName = attr.Value
+		case "xmloutputversion":
+			run.XMLOutputVersion = attr.Value
+		}
+	}
+	return nil
+}
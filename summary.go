@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lair-framework/go-lair"
+)
+
+// projectStats counts what a lair project holds.
+type projectStats struct {
+	hosts    int
+	services int
+	notes    int
+	issues   int
+	tags     []string
+}
+
+// statsOf counts the hosts, services, notes, issues, and distinct tags of
+// project. Notes include those on hosts and on services.
+func statsOf(project *lair.Project) projectStats {
+	stats := projectStats{hosts: len(project.Hosts), issues: len(project.Issues)}
+	for _, h := range project.Hosts {
+		stats.services += len(h.Services)
+		stats.notes += len(h.Notes)
+		for _, s := range h.Services {
+			stats.notes += len(s.Notes)
+		}
+		stats.tags = appendUnique(stats.tags, h.Tags...)
+	}
+	return stats
+}
+
+func (s projectStats) String() string {
+	summary := fmt.Sprintf("%d hosts, %d services, %d notes, %d issues", s.hosts, s.services, s.notes, s.issues)
+	if len(s.tags) > 0 {
+		summary += ", tags " + strings.Join(s.tags, ", ")
+	}
+	return summary
+}
+
+// logDryRun logs what importing project would add to the lair project, with
+// a line for each host.
+func logDryRun(project *lair.Project) {
+	for _, h := range project.Hosts {
+		stats := statsOf(&lair.Project{Hosts: []lair.Host{h}})
+		log.Printf("Info: Would import host %s: %d services, %d notes, tags %s", h.IPv4, stats.services, stats.notes, strings.Join(h.Tags, ", "))
+	}
+	log.Printf("Info: Would import %s", statsOf(project))
+}
+
+// importSummary totals what was imported across every input, for the
+// summary logged when drone-nmap finishes.
+type importSummary struct {
+	start   time.Time
+	skipped int
+	totals  projectStats
+	issues  map[string]bool
+}
+
+// newImportSummary returns a summary whose elapsed time starts now.
+func newImportSummary() *importSummary {
+	return &importSummary{start: time.Now(), issues: map[string]bool{}}
+}
+
+// skip counts a host that was up but not imported. It is safe to call on a
+// nil *importSummary.
+func (s *importSummary) skip() {
+	if s != nil {
+		s.skipped++
+	}
+}
+
+// record adds the contents of project to the summary. Issues are counted
+// once however many projects report them. It is safe to call on a nil
+// *importSummary.
+func (s *importSummary) record(project *lair.Project) {
+	if s == nil {
+		return
+	}
+	stats := statsOf(project)
+	s.totals.hosts += stats.hosts
+	s.totals.services += stats.services
+	s.totals.notes += stats.notes
+	s.totals.tags = appendUnique(s.totals.tags, stats.tags...)
+	for _, issue := range project.Issues {
+		s.issues[issue.Title] = true
+	}
+	s.totals.issues = len(s.issues)
+}
+
+// log logs the summary as a table, describing hosts with verb, such as
+// "imported".
+func (s *importSummary) log(verb string) {
+	tags := strings.Join(s.totals.tags, ", ")
+	if tags == "" {
+		tags = "none"
+	}
+	rows := [][2]string{
+		{"Hosts " + verb, fmt.Sprint(s.totals.hosts)},
+		{"Hosts skipped", fmt.Sprint(s.skipped)},
+		{"Services", fmt.Sprint(s.totals.services)},
+		{"Notes", fmt.Sprint(s.totals.notes)},
+		{"Issues", fmt.Sprint(s.totals.issues)},
+		{"Tags", tags},
+		{"Elapsed", time.Since(s.start).Round(time.Millisecond).String()},
+	}
+	for _, row := range rows {
+		log.Printf("Summary: %-15s %s", row[0], row[1])
+	}
+}
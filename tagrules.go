@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+)
+
+// tagRule adds tags to hosts in a network.
+type tagRule struct {
+	network *net.IPNet
+	tags    []string
+}
+
+// serviceTagRule adds tags to services whose name matches services.
+type serviceTagRule struct {
+	services *regexp.Regexp
+	tags     []string
+}
+
+// readTagRules reads a tag rules file. Each line holds an address or CIDR
+// range, an equals sign, and a comma separated list of tags for the hosts in
+// that range, such as "10.1.0.0/16 = dc,prod". Blank lines and lines
+// starting with # are ignored.
+func readTagRules(path string) ([]tagRule, error) {
+	var rules []tagRule
+	err := readRules(path, "cidr", func(key string, tags []string) error {
+		network, err := parseCIDR(key)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, tagRule{network: network, tags: tags})
+		return nil
+	})
+	return rules, err
+}
+
+// readServiceTagRules reads a service tag rules file. Each line holds a comma
+// separated list of service names or regular expressions, as accepted by
+// -services, an equals sign, and the tags for those services, such as
+// "https,ssl/.* = crypto".
+func readServiceTagRules(path string) ([]serviceTagRule, error) {
+	var rules []serviceTagRule
+	err := readRules(path, "services", func(key string, tags []string) error {
+		services, err := parseServices(key)
+		if err != nil {
+			return err
+		}
+		if services == nil {
+			return fmt.Errorf("No services given")
+		}
+		rules = append(rules, serviceTagRule{services: services, tags: tags})
+		return nil
+	})
+	return rules, err
+}
+
+// readRules reads a file of '<key> = <tags>' lines, calling add with the key
+// and tags of each. Blank lines and lines starting with # are ignored.
+func readRules(path, key string, add func(key string, tags []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Could not open tag rules. Error %s", err.Error())
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid tag rule on line %d of %s, expected '<%s> = <tags>'", line, path, key)
+		}
+		var tags []string
+		for _, tag := range strings.Split(parts[1], ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if err := add(strings.TrimSpace(parts[0]), tags); err != nil {
+			return fmt.Errorf("Invalid tag rule on line %d of %s. Error %s", line, path, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Could not read tag rules. Error %s", err.Error())
+	}
+	return nil
+}
+
+// ruleTags returns the tags of every rule whose range contains address.
+func ruleTags(rules []tagRule, address string) []string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil
+	}
+	var tags []string
+	for _, rule := range rules {
+		if rule.network.Contains(ip) {
+			tags = appendUnique(tags, rule.tags...)
+		}
+	}
+	return tags
+}
+
+// addServiceTags records the tags of every service tag rule matching the
+// name of service in a "Tags" service note, as Lair only tags hosts.
+func (b *hostBuilder) addServiceTags(service *lair.Service) {
+	var tags []string
+	for _, rule := range b.opts.serviceTags {
+		if rule.services.MatchString(service.Service) {
+			tags = appendUnique(tags, rule.tags...)
+		}
+	}
+	if len(tags) > 0 {
+		b.addNote(service, "Tags", strings.Join(tags, ", "))
+	}
+}
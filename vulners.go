@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+var (
+	vulnersCPERe   = regexp.MustCompile(`^(cpe:/\S+?):?$`)
+	vulnersEntryRe = regexp.MustCompile(`^(\S+)\s+(\d+(?:\.\d+)?)\s+(https?://\S+)(\s+\*EXPLOIT\*)?`)
+	vulscanEntryRe = regexp.MustCompile(`^\[(CVE-\d{4}-\d{4,})\]\s*(.*)$`)
+)
+
+// vulnEntry is a single vulnerability reported by vulners or vulscan.
+type vulnEntry struct {
+	id          string
+	cpes        []string
+	cvss        float64
+	exploit     bool
+	link        string
+	description string
+}
+
+// mapVulners creates an issue for each CVE the vulners script reported,
+// with its CVSS score and whether a public exploit is known.
+func mapVulners(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	entries := vulnersFromTables(script)
+	if len(entries) == 0 {
+		entries = vulnersFromOutput(script.Output)
+	}
+	return b.addCVEIssues(service, script, entries)
+}
+
+// mapVulscan creates an issue for each CVE the vulscan script matched in its
+// CVE database. Vulscan does not report scores, so the issues are rated
+// medium.
+func mapVulscan(b *hostBuilder, service *lair.Service, script *nmap.Script) bool {
+	var entries []*vulnEntry
+	for _, line := range strings.Split(script.Output, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "|_"))
+		if m := vulscanEntryRe.FindStringSubmatch(line); m != nil {
+			entries = append(entries, &vulnEntry{id: m[1], description: m[2]})
+		}
+	}
+	return b.addCVEIssues(service, script, entries)
+}
+
+// vulnersFromTables reads the structured output of vulners, which has a
+// table for each CPE holding a table for each vulnerability.
+func vulnersFromTables(script *nmap.Script) []*vulnEntry {
+	var entries []*vulnEntry
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		for j := range t.Table {
+			e := &t.Table[j]
+			entry := &vulnEntry{
+				id:      tableElem(e, "id"),
+				exploit: tableElem(e, "is_exploit") == "true",
+			}
+			if t.Key != "" {
+				entry.cpes = []string{t.Key}
+			}
+			entry.cvss, _ = strconv.ParseFloat(tableElem(e, "cvss"), 64)
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// vulnersFromOutput reads the text output of vulners, as found in normal
+// output, where each CPE line is followed by lines of the form
+// "CVE-2018-15919 5.0 https://vulners.com/cve/CVE-2018-15919 *EXPLOIT*".
+func vulnersFromOutput(output string) []*vulnEntry {
+	var entries []*vulnEntry
+	cpe := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "|_"))
+		if m := vulnersCPERe.FindStringSubmatch(line); m != nil {
+			cpe = m[1]
+			continue
+		}
+		m := vulnersEntryRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		entry := &vulnEntry{id: m[1], link: m[3], exploit: m[4] != ""}
+		if cpe != "" {
+			entry.cpes = []string{cpe}
+		}
+		entry.cvss, _ = strconv.ParseFloat(m[2], 64)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// addCVEIssues creates an issue for each CVE in entries, highest CVSS first.
+// Entries for the same CVE, such as one per affected CPE or vendor ids like
+// PRION:CVE-2019-9511, are combined under the CVE id.
+func (b *hostBuilder) addCVEIssues(service *lair.Service, script *nmap.Script, entries []*vulnEntry) bool {
+	var cves []*vulnEntry
+	index := map[string]*vulnEntry{}
+	for _, entry := range entries {
+		id := cveRe.FindString(entry.id)
+		if id == "" {
+			continue
+		}
+		if id != entry.id {
+			// The link of a vendor id points at the vendor's advisory
+			// rather than the CVE.
+			vendor := *entry
+			vendor.id, vendor.link = id, ""
+			entry = &vendor
+		}
+		if existing, ok := index[entry.id]; ok {
+			existing.cpes = appendUnique(existing.cpes, entry.cpes...)
+			existing.exploit = existing.exploit || entry.exploit
+			if entry.cvss > existing.cvss {
+				existing.cvss = entry.cvss
+			}
+			if existing.link == "" {
+				existing.link = entry.link
+			}
+			if existing.description == "" {
+				existing.description = entry.description
+			}
+			continue
+		}
+		index[entry.id] = entry
+		cves = append(cves, entry)
+	}
+	if len(cves) == 0 {
+		return false
+	}
+	sort.SliceStable(cves, func(i, j int) bool {
+		return cves[i].cvss > cves[j].cvss
+	})
+
+	for _, entry := range cves {
+		issue := newIssue(script, entry.id)
+		issue.CVEs = []string{entry.id}
+		setCVSS(&issue, entry.cvss, "")
+		var description []string
+		if entry.description != "" {
+			description = append(description, entry.description)
+		}
+		if len(entry.cpes) > 0 {
+			description = append(description, "Affected software: "+strings.Join(entry.cpes, ", "))
+		}
+		if entry.exploit {
+			description = append(description, "A public exploit is available.")
+		}
+		issue.Description = strings.Join(description, "\n")
+		issue.Evidence = fmt.Sprintf("%s reported %s", script.Id, entry.id)
+		if entry.cvss > 0 {
+			issue.Evidence += fmt.Sprintf(" with CVSS %.1f", entry.cvss)
+		}
+		link := entry.link
+		if link == "" {
+			link = "https://nvd.nist.gov/vuln/detail/" + entry.id
+		}
+		issue.References = []lair.IssueReference{{Link: link}}
+		b.addIssue(service, issue)
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-lair"
+	"github.com/lair-framework/go-nmap"
+)
+
+var (
+	cveRe        = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+	riskFactorRe = regexp.MustCompile(`(?i)Risk factor:\s*(\w+)`)
+	vulnStateRe  = regexp.MustCompile(`(?m)^\s*State:\s*(.*VULNERABLE.*)$`)
+	vulnURLRe    = regexp.MustCompile(`https?://\S+`)
+)
+
+// addVulnIssues creates issues for the vulnerabilities reported by script.
+// It understands the output of scripts built on nmap's vulns library, such
+// as smb-vuln-* and http-vuln-*, and reports whether any issues were
+// created. Findings reported as not vulnerable are left as notes.
+func (b *hostBuilder) addVulnIssues(service *lair.Service, script *nmap.Script) bool {
+	found := false
+	for i := range script.Tables {
+		t := &script.Tables[i]
+		state := tableElem(t, "state")
+		if !isVulnerable(state) {
+			continue
+		}
+		title := tableElem(t, "title")
+		if title == "" {
+			title = script.Id
+		}
+		issue := newIssue(script, title)
+		issue.Description = strings.Join(tableList(t, "description"), "\n")
+		for _, id := range tableList(t, "ids") {
+			issue.CVEs = appendUnique(issue.CVEs, cveRe.FindAllString(id, -1)...)
+		}
+		if t.Key != "" {
+			issue.CVEs = appendUnique(issue.CVEs, cveRe.FindAllString(t.Key, -1)...)
+		}
+		for _, ref := range tableList(t, "refs") {
+			issue.References = append(issue.References, lair.IssueReference{Link: ref})
+		}
+		risk := tableElem(t, "risk_factor")
+		if risk == "" {
+			risk = riskFactor(script.Output)
+		}
+		setCVSS(&issue, scoreFromTable(t), risk)
+		issue.IsConfirmed = strings.HasPrefix(state, "VULNERABLE")
+		b.addIssue(service, issue)
+		found = true
+	}
+	if found || len(script.Tables) > 0 {
+		return found
+	}
+	return b.addVulnIssueFromText(service, script)
+}
+
+// addVulnIssueFromText handles vulns library output where only the text
+// output is available, such as scripts recovered from normal output.
+func (b *hostBuilder) addVulnIssueFromText(service *lair.Service, script *nmap.Script) bool {
+	m := vulnStateRe.FindStringSubmatch(script.Output)
+	if m == nil || !isVulnerable(strings.TrimSpace(m[1])) {
+		return false
+	}
+	title := script.Id
+	lines := strings.Split(script.Output, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "VULNERABLE:" && i+1 < len(lines) {
+			title = strings.TrimSpace(lines[i+1])
+			break
+		}
+	}
+	issue := newIssue(script, title)
+	issue.CVEs = appendUnique(issue.CVEs, cveRe.FindAllString(script.Output, -1)...)
+	for _, ref := range vulnURLRe.FindAllString(script.Output, -1) {
+		issue.References = append(issue.References, lair.IssueReference{Link: ref})
+	}
+	setCVSS(&issue, 0, riskFactor(script.Output))
+	issue.IsConfirmed = strings.HasPrefix(strings.TrimSpace(m[1]), "VULNERABLE")
+	b.addIssue(service, issue)
+	return true
+}
+
+// riskFactor returns the risk factor given in the text output of a vulns
+// library script. Scripts that report several findings share the first one.
+func riskFactor(output string) string {
+	if m := riskFactorRe.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// isVulnerable reports whether a vulns library state indicates that the
+// target is, or is likely to be, vulnerable.
+func isVulnerable(state string) bool {
+	state = strings.ToUpper(state)
+	return strings.Contains(state, "VULNERABLE") && !strings.Contains(state, "NOT VULNERABLE")
+}
+
+// setCVSS sets the CVSS score and rating of issue from score, falling back
+// to the risk factor when no score is known.
+func setCVSS(issue *lair.Issue, score float64, risk string) {
+	if score == 0 {
+		switch strings.ToUpper(risk) {
+		case "HIGH", "CRITICAL":
+			score = cvssHigh
+		case "LOW":
+			score = cvssLow
+		default:
+			score = cvssMedium
+		}
+	}
+	issue.CVSS = score
+	issue.Rating = rating(score)
+}
+
+// scoreFromTable returns the highest CVSS score in the scores table of a
+// vulns library result.
+func scoreFromTable(t *nmap.Table) float64 {
+	score := 0.0
+	for i := range t.Table {
+		if t.Table[i].Key != "scores" {
+			continue
+		}
+		for _, e := range t.Table[i].Elements {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(e.Value), 64); err == nil && v > score {
+				score = v
+			}
+		}
+	}
+	return score
+}
+
+// tableElem returns the value of the element with key in t.
+func tableElem(t *nmap.Table, key string) string {
+	for _, e := range t.Elements {
+		if e.Key == key {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	return ""
+}
+
+// tableList returns the values of the elements in the subtable of t with
+// key.
+func tableList(t *nmap.Table, key string) []string {
+	var values []string
+	for i := range t.Table {
+		if t.Table[i].Key != key {
+			continue
+		}
+		for _, e := range t.Table[i].Elements {
+			if v := strings.TrimSpace(e.Value); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lair-framework/go-nmap"
+)
+
+const zmap = "zmap"
+
+// parseZmap parses zmap CSV output (-O csv). The header row must include
+// the saddr field; sport, success, and classification are used when present.
+// Rows without a sport field produce hosts without services.
+func parseZmap(r io.Reader) (*nmap.NmapRun, error) {
+	set := newHostSet(zmap)
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read zmap CSV header. Error %s", err.Error())
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	saddr, ok := columns["saddr"]
+	if !ok {
+		return nil, errors.New("zmap CSV is missing the saddr field")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if saddr >= len(record) || record[saddr] == "" {
+			continue
+		}
+		if success := field(record, "success"); success == "0" || success == "false" {
+			continue
+		}
+		ip := strings.TrimSpace(record[saddr])
+		sport := field(record, "sport")
+		if sport == "" {
+			set.host(ip)
+			continue
+		}
+		id, err := strconv.Atoi(sport)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid zmap sport %q", sport)
+		}
+		protocol := "tcp"
+		if strings.Contains(field(record, "classification"), "udp") {
+			protocol = "udp"
+		}
+		set.port(ip, protocol, id).State.State = "open"
+	}
+	return set.run, nil
+}